@@ -1,8 +1,6 @@
 package api
 
 import (
-	"strings"
-
 	"github.com/MinnaSync/minna-sync-backend/config"
 	"github.com/MinnaSync/minna-sync-backend/handlers"
 	"github.com/MinnaSync/minna-sync-backend/internal/ws"
@@ -13,9 +11,13 @@ import (
 func Register(app *fiber.App) {
 	app.Use("/ws", handlers.WSUpgrader)
 	app.Get("/ws", websocket.New(Websocket, websocket.Config{
-		Origins: strings.Split(config.Conf.AllowOrigins, ","),
-
 		ReadBufferSize:  ws.MaxBufferSize,
 		WriteBufferSize: ws.MaxBufferSize,
 	}))
+
+	// The stats are internal, so they are only served when a token to guard them with is configured.
+	if config.Conf.StatsToken != "" {
+		stats := app.Group("/stats", requireStatsToken)
+		stats.Get("/probe_cache", ProbeCacheStats)
+	}
 }
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/MinnaSync/minna-sync-backend/internal/prober"
+	"github.com/MinnaSync/minna-sync-backend/internal/ws"
+)
+
+var (
+	ErrMediaNotStructure = errors.New("media is not a structure")
+	ErrMediaInvalidID    = errors.New("media ID is not a string")
+	ErrMediaInvalidURL   = errors.New("media URL is not a string")
+	ErrTooManyChapters   = errors.New("media has too many chapters")
+)
+
+// Parses media sent by a client, without probing its duration.
+func parseMedia(client *ws.Client, msg any) (ws.Media, error) {
+	var mediaData ws.Media
+
+	media, ok := msg.(map[string]any)
+	if !ok {
+		return mediaData, ErrMediaNotStructure
+	}
+
+	id, ok := media["id"].(string)
+	if !ok {
+		return mediaData, ErrMediaInvalidID
+	}
+	mediaData.ID = id
+
+	if title, ok := media["title"].(string); ok {
+		mediaData.Title = &title
+	}
+
+	url, ok := media["url"].(string)
+	if !ok {
+		return mediaData, ErrMediaInvalidURL
+	}
+	mediaData.URL = url
+
+	if series, ok := media["series"].(string); ok {
+		mediaData.Series = &series
+	}
+
+	if episode, ok := media["episode"].(float64); ok {
+		i := int(episode)
+		mediaData.Episode = &i
+	}
+
+	if posterImageURL, ok := media["poster_image_url"].(string); ok {
+		mediaData.PosterImageURL = &posterImageURL
+	}
+
+	if chapters, ok := media["chapters"].([]any); ok {
+		if len(chapters) > ws.MaxChapters {
+			return mediaData, ErrTooManyChapters
+		}
+
+		mediaData.Chapters = parseChapters(chapters)
+	}
+
+	mediaData.QueuedBy = &ws.Submitter{
+		ID:       client.User.ID,
+		Username: client.User.Username,
+	}
+
+	return mediaData, nil
+}
+
+// Parses chapter markers sent by a client, leaving out any that don't make sense.
+func parseChapters(chapters []any) []ws.Chapter {
+	parsed := make([]ws.Chapter, 0, len(chapters))
+
+	for _, c := range chapters {
+		chapter, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		start, ok := chapter["start"].(float64)
+		if !ok || math.IsNaN(start) || start < 0 {
+			continue
+		}
+
+		end, ok := chapter["end"].(float64)
+		if !ok || math.IsNaN(end) || math.IsInf(end, 0) || end <= start {
+			continue
+		}
+
+		marker := ws.Chapter{
+			Start: start,
+			End:   end,
+		}
+
+		if title, ok := chapter["title"].(string); ok {
+			marker.Title = title
+		}
+
+		switch kind := chapter["kind"].(type) {
+		case float64:
+			if kind != math.Trunc(kind) || kind < float64(ws.ChapterKindChapter) || kind > float64(ws.ChapterKindCredits) {
+				continue
+			}
+			marker.Kind = ws.ChapterKind(kind)
+		case string:
+			marker.Kind = ws.ParseChapterKind(kind)
+		}
+
+		parsed = append(parsed, marker)
+	}
+
+	return parsed
+}
+
+// Probes the media's duration and stream info, filling in chapters from the playlist when the client didn't send any.
+func probe(ctx context.Context, media *ws.Media) error {
+	result, err := prober.Probe(ctx, media.URL)
+	if err != nil {
+		return err
+	}
+
+	media.Duration = result.Duration
+	media.Width = result.Width
+	media.Height = result.Height
+	media.Codecs = result.Codecs
+
+	if len(media.Chapters) != 0 {
+		return nil
+	}
+
+	for _, r := range result.DateRanges {
+		if len(media.Chapters) >= ws.MaxChapters {
+			break
+		}
+
+		// Ranges without an end run until the end of the media.
+		end := media.Duration
+		if r.End != nil {
+			end = *r.End
+		}
+
+		if r.Start < 0 || end <= r.Start {
+			continue
+		}
+
+		media.Chapters = append(media.Chapters, ws.Chapter{
+			Title: r.ID,
+			Start: r.Start,
+			End:   min(end, media.Duration),
+			Kind:  ws.ParseChapterKind(r.Class + " " + r.ID),
+		})
+	}
+
+	return nil
+}
+
+// mediaProber probes media queued in channels.
+type mediaProber struct{}
+
+func (mediaProber) ProbeMedia(ctx context.Context, m *ws.Media) error {
+	return probe(ctx, m)
+}
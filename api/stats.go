@@ -0,0 +1,24 @@
+package api
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/MinnaSync/minna-sync-backend/config"
+	"github.com/MinnaSync/minna-sync-backend/internal/prober"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Only lets through requests carrying STATS_TOKEN as a bearer token.
+func requireStatsToken(c *fiber.Ctx) error {
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(config.Conf.StatsToken)) != 1 {
+		return fiber.ErrUnauthorized
+	}
+
+	return c.Next()
+}
+
+func ProbeCacheStats(c *fiber.Ctx) error {
+	return c.JSON(prober.Stats())
+}
@@ -1,16 +1,77 @@
 package api
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/MinnaSync/minna-sync-backend/internal/m3u8_duration"
+	"github.com/MinnaSync/minna-sync-backend/config"
+	"github.com/MinnaSync/minna-sync-backend/handlers"
+	"github.com/MinnaSync/minna-sync-backend/internal/catalog"
+	"github.com/MinnaSync/minna-sync-backend/internal/guest_token"
+	"github.com/MinnaSync/minna-sync-backend/internal/prober"
 	"github.com/MinnaSync/minna-sync-backend/internal/ws"
 	"github.com/gofiber/contrib/websocket"
 
 	log "github.com/sirupsen/logrus"
 )
 
+var guestTokens = guest_token.NewSigner([]byte(config.Conf.GuestTokenSecret), config.Conf.GuestTokenMaxAge)
+
+func init() {
+	if config.Conf.GuestTokenSecret == "" {
+		log.Warn("GUEST_TOKEN_SECRET is not set, using a random secret for this process. Guest tokens will stop working after a restart.")
+	}
+
+	for _, limit := range config.Conf.EventRateLimits {
+		event, bucket, _ := strings.Cut(strings.TrimSpace(limit), "=")
+		rate, burst, _ := strings.Cut(bucket, ":")
+
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			log.WithField("limit", limit).Warn("Ignoring event rate limit with an invalid rate.")
+			continue
+		}
+
+		b, err := strconv.ParseFloat(burst, 64)
+		if err != nil {
+			log.WithField("limit", limit).Warn("Ignoring event rate limit with an invalid burst.")
+			continue
+		}
+
+		ws.EventRateLimits[event] = ws.RateLimit{Rate: r, Burst: b}
+	}
+
+	ws.MaxQueueLength = config.Conf.MaxQueueLength
+	ws.MaxQueuedPerMember = config.Conf.MaxQueuedPerMember
+	ws.MaxQueueDuration = config.Conf.MaxQueueDuration
+
+	ws.DriftThreshold = config.Conf.DriftThreshold
+	ws.SeekThreshold = config.Conf.SeekThreshold
+
+	ws.Prober = mediaProber{}
+	ws.ProbeConcurrency = config.Conf.ProbeConcurrency
+	prober.Timeout = config.Conf.ProbeTimeout
+	prober.MaxRedirects = config.Conf.ProbeMaxRedirects
+	prober.CacheTTL = config.Conf.ProbeCacheTTL
+	prober.CacheSize = config.Conf.ProbeCacheSize
+
+	if config.Conf.AutoplayCatalog != "" {
+		c, err := catalog.Load(config.Conf.AutoplayCatalog)
+		if err != nil {
+			log.WithError(err).Error("Failed to load the autoplay catalog.")
+		} else {
+			ws.NextEpisodes = c
+		}
+	}
+}
+
 func Websocket(c *websocket.Conn) {
+	if ip, ok := c.Locals("ip").(string); ok {
+		defer handlers.ReleaseConnection(ip)
+	}
+
 	client := ws.Serve(c)
 
 	// TODO: This flow needs to be refactored.
@@ -24,6 +85,28 @@ func Websocket(c *websocket.Conn) {
 		client.Emit("connected", map[string]any{})
 	})
 
+	client.On("time_sync", func(msg any) {
+		received := client.ReceivedAt()
+
+		request, ok := msg.(map[string]any)
+		if !ok {
+			log.Debug("Time failed to sync. Request is not a structure.")
+			return
+		}
+
+		clientTime, ok := request["client_time"].(float64)
+		if !ok {
+			log.Debug("Time failed to sync. Client time is not a number.")
+			return
+		}
+
+		client.Emit("time_sync", ws.TimeSync{
+			ClientTime:        clientTime,
+			ServerReceiveTime: received.UnixMilli(),
+			ServerSendTime:    time.Now().UnixMilli(),
+		})
+	})
+
 	client.On("join_channel", func(msg any) {
 		joinInfo, ok := msg.(map[string]any)
 		if !ok {
@@ -37,28 +120,38 @@ func Websocket(c *websocket.Conn) {
 			return
 		}
 
+		if token, ok := joinInfo["guest_token"].(string); ok {
+			claims, err := guestTokens.Verify(token)
+			if err != nil {
+				log.WithError(err).Debug("Client provided an invalid guest token.")
+			} else {
+				client.User.ID = claims.GuestID
+				client.User.Username = claims.Username
+			}
+		}
+
 		if username, ok := joinInfo["guest_username"].(string); ok {
 			if len := len(username); len >= 3 && len <= 16 {
 				client.User.Username = username
 			}
 		}
 
-		channel := client.ChannelConnect(channelId)
+		token, err := guestTokens.Sign(client.User.ID, client.User.Username)
+		if err != nil {
+			log.WithError(err).Error("Failed to sign guest token.")
+		} else {
+			client.Emit("guest_token", ws.GuestToken{
+				Token: token,
+			})
+		}
 
-		var nowPlaying *ws.NowPlayingMedia
-		if channel.Playing != nil {
-			nowPlaying = &ws.NowPlayingMedia{
-				Media:       channel.Playing.Media,
-				Paused:      channel.Playing.Paused,
-				CurrentTime: channel.Playing.CurrentPlaybackTime(),
-			}
+		if spectator, ok := joinInfo["spectator"].(bool); ok {
+			client.Spectator = spectator
 		}
 
-		client.Emit("room_data", ws.RoomData{
-			NowPlaying: nowPlaying,
-			Queue:      channel.Queued,
-			Messages:   channel.Messages,
-		})
+		channel := client.ChannelConnect(channelId)
+
+		client.Emit("room_data", channel.RoomData())
 
 		client.On("send_message", func(msg any) {
 			messageContent, ok := msg.(map[string]any)
@@ -82,53 +175,75 @@ func Websocket(c *websocket.Conn) {
 		})
 
 		client.On("queue_media", func(msg any) {
-			var mediaData ws.Media
-
-			media, ok := msg.(map[string]any)
-			if !ok {
-				log.Debug("Media failed to queue. Media is not a structure.")
+			mediaData, err := parseMedia(client, msg)
+			if err != nil {
+				log.WithError(err).Debug("Media failed to queue.")
 				return
 			}
 
-			id, ok := media["id"].(string)
-			if !ok {
-				log.Debug("Media failed to queue. Media ID is not a string.")
-				return
+			media := msg.(map[string]any)
+
+			options := ws.QueueOptions{
+				Position: ws.QueuePositionEnd,
 			}
-			mediaData.ID = id
 
-			if title, ok := media["title"].(string); ok {
-				mediaData.Title = &title
+			switch position := media["position"].(type) {
+			case string:
+				if position == "next" {
+					options.Position = 0
+				}
+			case float64:
+				options.Position = int(position)
 			}
 
-			url, ok := media["url"].(string)
-			if !ok {
-				log.Debug("Media failed to queue. Media URL is not a string.")
-				return
+			if playNow, ok := media["play_now"].(bool); ok {
+				options.PlayNow = playNow
 			}
-			mediaData.URL = url
 
-			duration, err := m3u8_duration.FetchM3u8Duration(url)
-			if err != nil {
-				log.Debug("Media failed to queue. Failed to fetch duration.")
+			if err := channel.QueueInsert(client, mediaData, options); err != nil {
+				client.EmitError("queue_media", err.Error())
+			}
+		})
+
+		client.On("queue_media_batch", func(msg any) {
+			batch, ok := msg.(map[string]any)
+			if !ok {
+				log.Debug("Media batch failed to queue. Batch is not a structure.")
 				return
 			}
-			mediaData.Duration = duration
 
-			if series, ok := media["series"].(string); ok {
-				mediaData.Series = &series
+			items, ok := batch["media"].([]any)
+			if !ok {
+				log.Debug("Media batch failed to queue. Media is not a list.")
+				return
 			}
 
-			if episode, ok := media["episode"].(float64); ok {
-				i := int(episode)
-				mediaData.Episode = &i
+			if len(items) > ws.MaxBatchSize {
+				client.EmitError("queue_media_batch", fmt.Sprintf("Only %d media can be queued at once.", ws.MaxBatchSize))
+				return
 			}
 
-			if posterImageURL, ok := media["poster_image_url"].(string); ok {
-				mediaData.PosterImageURL = &posterImageURL
+			failed := make([]ws.BatchFailure, 0)
+			parsed := make([]ws.BatchItem, 0, len(items))
+			for i, item := range items {
+				mediaData, err := parseMedia(client, item)
+				if err != nil {
+					failed = append(failed, ws.BatchFailure{
+						Index:  i,
+						ID:     mediaData.ID,
+						Reason: err.Error(),
+					})
+					continue
+				}
+
+				parsed = append(parsed, ws.BatchItem{
+					Index: i,
+					Media: mediaData,
+				})
 			}
 
-			channel.QueueInsert(mediaData)
+			// The channel reports on the batch once its media has been probed.
+			channel.QueueInsertBatch(client, parsed, failed)
 		})
 
 		client.On("player_state", func(msg any) {
@@ -147,9 +262,87 @@ func Websocket(c *websocket.Conn) {
 				updatedState.CurrentTime = &currentTime
 			}
 
+			if playbackRate, ok := state["playback_rate"].(float64); ok {
+				updatedState.PlaybackRate = &playbackRate
+			}
+
 			channel.PlayerState(client, updatedState)
 		})
 
+		client.On("schedule_start", func(msg any) {
+			schedule, ok := msg.(map[string]any)
+			if !ok {
+				log.Debug("Start failed to schedule. Schedule is not a structure.")
+				return
+			}
+
+			startAt, ok := schedule["start_at"].(float64)
+			if !ok {
+				log.Debug("Start failed to schedule. Start time is not a number.")
+				return
+			}
+
+			next, _ := schedule["next"].(bool)
+
+			if err := channel.ScheduleStart(client, time.UnixMilli(int64(startAt)), next); err != nil {
+				client.EmitError("schedule_start", err.Error())
+			}
+		})
+
+		client.On("cancel_scheduled_start", func(msg any) {
+			if err := channel.CancelScheduledStart(client); err != nil {
+				client.EmitError("cancel_scheduled_start", err.Error())
+			}
+		})
+
+		client.On("buffering_state", func(msg any) {
+			state, ok := msg.(map[string]any)
+			if !ok {
+				log.Debug("Buffering state failed to update. State is not a structure.")
+				return
+			}
+
+			buffering, ok := state["buffering"].(bool)
+			if !ok {
+				log.Debug("Buffering state failed to update. Buffering is not a boolean.")
+				return
+			}
+
+			channel.SetBuffering(client, buffering)
+		})
+
+		client.On("media_ready", func(msg any) {
+			ready, ok := msg.(map[string]any)
+			if !ok {
+				log.Debug("Media failed to ready. Ready is not a structure.")
+				return
+			}
+
+			entryId, ok := ready["entry_id"].(string)
+			if !ok {
+				log.Debug("Media failed to ready. Entry ID is not a string.")
+				return
+			}
+
+			channel.MediaReady(client, entryId)
+		})
+
+		client.On("position_report", func(msg any) {
+			report, ok := msg.(map[string]any)
+			if !ok {
+				log.Debug("Position failed to report. Report is not a structure.")
+				return
+			}
+
+			currentTime, ok := report["current_time"].(float64)
+			if !ok {
+				log.Debug("Position failed to report. Current time is not a number.")
+				return
+			}
+
+			channel.ReportPosition(client, currentTime)
+		})
+
 		client.On("run_command", func(msg any) {
 			command, ok := msg.(map[string]any)
 			if !ok {
@@ -173,9 +366,59 @@ func Websocket(c *websocket.Conn) {
 			case ws.CommandTypeSkip:
 				channel.QueueChange()
 				break
+			case ws.CommandTypeShuffle:
+				channel.QueueShuffle(client)
+				break
+			case ws.CommandTypePrevious:
+				channel.QueuePrevious()
+				break
+			case ws.CommandTypeSkipSegment:
+				channel.SkipSegment(client)
+				break
 			}
 		})
 
+		client.On("set_playback_mode", func(msg any) {
+			modeData, ok := msg.(map[string]any)
+			if !ok {
+				log.Debug("Playback mode failed to change. Mode is not a structure.")
+				return
+			}
+
+			update := ws.PlaybackModeUpdated{}
+
+			if repeat, ok := modeData["repeat"].(float64); ok {
+				mode := ws.RepeatMode(repeat)
+				update.Repeat = &mode
+			}
+
+			if shuffle, ok := modeData["shuffle"].(bool); ok {
+				update.Shuffle = &shuffle
+			}
+
+			if fair, ok := modeData["fair"].(bool); ok {
+				update.Fair = &fair
+			}
+
+			if autoplay, ok := modeData["autoplay"].(bool); ok {
+				update.Autoplay = &autoplay
+			}
+
+			if waitForAll, ok := modeData["wait_for_all"].(bool); ok {
+				update.WaitForAll = &waitForAll
+			}
+
+			if readyCheck, ok := modeData["ready_check"].(bool); ok {
+				update.ReadyCheck = &readyCheck
+			}
+
+			if skipCredits, ok := modeData["skip_credits"].(bool); ok {
+				update.SkipCredits = &skipCredits
+			}
+
+			channel.SetPlaybackMode(client, update)
+		})
+
 		client.On("queue_remove", func(msg any) {
 			mediaId, ok := msg.(map[string]any)
 			if !ok {
@@ -183,13 +426,35 @@ func Websocket(c *websocket.Conn) {
 				return
 			}
 
-			id, ok := mediaId["id"].(string)
+			entryId, ok := mediaId["entry_id"].(string)
+			if !ok {
+				log.Debug("Media ID failed to remove. Entry ID is not a string.")
+				return
+			}
+
+			channel.QueueRemove(client, entryId)
+		})
+
+		client.On("queue_move", func(msg any) {
+			move, ok := msg.(map[string]any)
+			if !ok {
+				log.Debug("Media failed to move. Move is not a structure.")
+				return
+			}
+
+			entryId, ok := move["entry_id"].(string)
+			if !ok {
+				log.Debug("Media failed to move. Entry ID is not a string.")
+				return
+			}
+
+			index, ok := move["index"].(float64)
 			if !ok {
-				log.Debug("Media ID failed to remove. Media ID is not a string.")
+				log.Debug("Media failed to move. Index is not a number.")
 				return
 			}
 
-			channel.QueueRemove(id)
+			channel.QueueMove(client, entryId, int(index))
 		})
 	})
 
@@ -2,6 +2,7 @@ package config
 
 import (
 	"sync"
+	"time"
 
 	"github.com/caarlos0/env"
 )
@@ -11,7 +12,43 @@ type (
 		Port         string `env:"PORT" envDefault:"8080"`
 		AllowOrigins string `env:"ALLOW_ORIGINS"`
 
+		// Addresses or CIDR ranges of proxies allowed to set X-Forwarded-For.
+		TrustedProxies       []string      `env:"TRUSTED_PROXIES" envSeparator:","`
+		MaxConnectionsPerIP  int           `env:"MAX_CONNECTIONS_PER_IP" envDefault:"10"`
+		ConnectionRateLimit  int           `env:"CONNECTION_RATE_LIMIT" envDefault:"30"`
+		ConnectionRateWindow time.Duration `env:"CONNECTION_RATE_WINDOW" envDefault:"1m"`
+
+		// Limits on what can be queued in a room, 0 disables the limit.
+		MaxQueueLength     int           `env:"MAX_QUEUE_LENGTH" envDefault:"200"`
+		MaxQueuedPerMember int           `env:"MAX_QUEUED_PER_MEMBER" envDefault:"25"`
+		MaxQueueDuration   time.Duration `env:"MAX_QUEUE_DURATION" envDefault:"0"`
+
+		// How far a client can drift from the server before it is corrected with a playback rate nudge or a seek.
+		DriftThreshold time.Duration `env:"DRIFT_THRESHOLD" envDefault:"300ms"`
+		SeekThreshold  time.Duration `env:"SEEK_THRESHOLD" envDefault:"2s"`
+
+		// A JSON file or directory of JSON files listing episodes to autoplay once a queue runs dry.
+		AutoplayCatalog string `env:"AUTOPLAY_CATALOG"`
+
+		// How many media can be probed at once, how long a probe can take and how many redirects it follows.
+		ProbeConcurrency  int           `env:"PROBE_CONCURRENCY" envDefault:"4"`
+		ProbeTimeout      time.Duration `env:"PROBE_TIMEOUT" envDefault:"15s"`
+		ProbeMaxRedirects int           `env:"PROBE_MAX_REDIRECTS" envDefault:"5"`
+
+		// How long probe results are cached for and how many are kept, 0 disables the cache.
+		ProbeCacheTTL  time.Duration `env:"PROBE_CACHE_TTL" envDefault:"10m"`
+		ProbeCacheSize int           `env:"PROBE_CACHE_SIZE" envDefault:"1000"`
+
+		// Per event rate limit overrides, formatted as event=rate:burst (e.g. queue_media=0.5:3).
+		EventRateLimits []string `env:"EVENT_RATE_LIMITS" envSeparator:","`
+
 		LogLevel string `env:"LOG_LEVEL" envDefault:"info"`
+
+		// The bearer token required to read the /stats endpoints, which are disabled when unset.
+		StatsToken string `env:"STATS_TOKEN"`
+
+		GuestTokenSecret string        `env:"GUEST_TOKEN_SECRET"`
+		GuestTokenMaxAge time.Duration `env:"GUEST_TOKEN_MAX_AGE" envDefault:"720h"`
 	}
 )
 
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type connectionLimiter struct {
+	mu sync.Mutex
+
+	maxActive int
+	maxRate   int
+	window    time.Duration
+
+	active   map[string]int
+	attempts map[string][]time.Time
+}
+
+func newConnectionLimiter(maxActive int, maxRate int, window time.Duration) *connectionLimiter {
+	l := &connectionLimiter{
+		maxActive: maxActive,
+		maxRate:   maxRate,
+		window:    window,
+
+		active:   make(map[string]int),
+		attempts: make(map[string][]time.Time),
+	}
+
+	if window > 0 {
+		go l.sweep()
+	}
+
+	return l
+}
+
+// Acquire reserves a connection slot for the address.
+// When a limit is hit, it returns false and how long until another attempt may succeed, if known.
+func (l *connectionLimiter) Acquire(ip string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	if l.maxRate > 0 && l.window > 0 {
+		attempts := l.recentAttempts(ip, now)
+		if len(attempts) >= l.maxRate {
+			return attempts[0].Add(l.window).Sub(now), false
+		}
+
+		l.attempts[ip] = append(attempts, now)
+	}
+
+	if l.maxActive > 0 && l.active[ip] >= l.maxActive {
+		return 0, false
+	}
+
+	l.active[ip]++
+	return 0, true
+}
+
+func (l *connectionLimiter) Release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active[ip] <= 1 {
+		delete(l.active, ip)
+		return
+	}
+
+	l.active[ip]--
+}
+
+func (l *connectionLimiter) recentAttempts(ip string, now time.Time) []time.Time {
+	attempts := l.attempts[ip]
+
+	i := 0
+	for i < len(attempts) && now.Sub(attempts[i]) >= l.window {
+		i++
+	}
+
+	return attempts[i:]
+}
+
+// Removes attempt history for addresses that have not connected within the window.
+func (l *connectionLimiter) sweep() {
+	ticker := time.NewTicker(l.window)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		l.mu.Lock()
+
+		for ip := range l.attempts {
+			if attempts := l.recentAttempts(ip, now); len(attempts) > 0 {
+				l.attempts[ip] = attempts
+			} else {
+				delete(l.attempts, ip)
+			}
+		}
+
+		l.mu.Unlock()
+	}
+}
+
+func parseProxies(proxies []string) []*net.IPNet {
+	parsed := make([]*net.IPNet, 0, len(proxies))
+
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			log.WithField("proxy", proxy).Warn("Ignoring invalid trusted proxy.")
+			continue
+		}
+
+		parsed = append(parsed, network)
+	}
+
+	return parsed
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP resolves the address of the client.
+// X-Forwarded-For is only honoured when the request came through a trusted proxy,
+// in which case the right-most address that is not itself a trusted proxy is used.
+func ClientIP(c *fiber.Ctx) string {
+	remote := c.Context().RemoteIP()
+	if !isTrustedProxy(remote) {
+		return remote.String()
+	}
+
+	forwarded := strings.Split(c.Get(fiber.HeaderXForwardedFor), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+
+		if !isTrustedProxy(ip) {
+			return ip.String()
+		}
+	}
+
+	return remote.String()
+}
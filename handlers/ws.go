@@ -1,15 +1,91 @@
 package handlers
 
 import (
+	"strconv"
+	"strings"
+
+	"github.com/MinnaSync/minna-sync-backend/config"
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+
+	log "github.com/sirupsen/logrus"
 )
 
+var (
+	allowedOrigins = parseOrigins(config.Conf.AllowOrigins)
+	trustedProxies = parseProxies(config.Conf.TrustedProxies)
+
+	connections = newConnectionLimiter(
+		config.Conf.MaxConnectionsPerIP,
+		config.Conf.ConnectionRateLimit,
+		config.Conf.ConnectionRateWindow,
+	)
+)
+
+func parseOrigins(origins string) []string {
+	parsed := make([]string, 0)
+
+	for _, origin := range strings.Split(origins, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+
+		parsed = append(parsed, origin)
+	}
+
+	return parsed
+}
+
+// Checks the origin against ALLOW_ORIGINS. With no origins configured, only clients that
+// don't send an origin are allowed, so browsers are turned away unless explicitly allowed.
+func originAllowed(origin string) bool {
+	if len(allowedOrigins) == 0 {
+		return origin == ""
+	}
+
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+
+	return false
+}
+
 func WSUpgrader(c *fiber.Ctx) error {
 	if !websocket.IsWebSocketUpgrade(c) {
 		return fiber.ErrUpgradeRequired
 	}
 
+	if !originAllowed(c.Get(fiber.HeaderOrigin)) {
+		return fiber.ErrForbidden
+	}
+
+	ip := ClientIP(c)
+	if retryAfter, ok := connections.Acquire(ip); !ok {
+		log.WithField("ip", ip).Debug("Client exceeded the connection limit.")
+
+		if retryAfter > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(retryAfter.Seconds())+1))
+		}
+
+		return fiber.ErrTooManyRequests
+	}
+
 	c.Locals("allowed", true)
-	return c.Next()
+	c.Locals("ip", ip)
+
+	// The connection never made it to the websocket handler, so it will never be released there.
+	if err := c.Next(); err != nil {
+		connections.Release(ip)
+		return err
+	}
+
+	return nil
+}
+
+// ReleaseConnection frees the connection slot held by the given address once its socket closes.
+func ReleaseConnection(ip string) {
+	connections.Release(ip)
 }
@@ -0,0 +1,112 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MinnaSync/minna-sync-backend/internal/prober"
+	"github.com/MinnaSync/minna-sync-backend/internal/ws"
+)
+
+// Entry is a single episode in the catalog.
+type Entry struct {
+	ID             string  `json:"id"`
+	Title          *string `json:"title"`
+	Series         string  `json:"series"`
+	Episode        int     `json:"episode"`
+	URL            string  `json:"url"`
+	PosterImageURL *string `json:"poster_image_url"`
+	// The duration in seconds, probed from the URL when not set.
+	Duration float64 `json:"duration"`
+}
+
+// Catalog is a NextEpisodeProvider backed by local JSON files.
+type Catalog struct {
+	series map[string]map[int]Entry
+}
+
+// Load reads a catalog from a JSON file containing a list of entries,
+// or from a directory of such files.
+func Load(path string) (*Catalog, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c := &Catalog{
+		series: make(map[string]map[int]Entry),
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var entries []Entry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			key := normalize(entry.Series)
+			if _, ok := c.series[key]; !ok {
+				c.series[key] = make(map[int]Entry)
+			}
+
+			c.series[key][entry.Episode] = entry
+		}
+	}
+
+	return c, nil
+}
+
+func normalize(series string) string {
+	return strings.ToLower(strings.TrimSpace(series))
+}
+
+func (c *Catalog) NextEpisode(m ws.Media) (*ws.Media, error) {
+	if m.Series == nil || m.Episode == nil {
+		return nil, nil
+	}
+
+	entry, ok := c.series[normalize(*m.Series)][*m.Episode+1]
+	if !ok {
+		return nil, nil
+	}
+
+	media := &ws.Media{
+		ID:             entry.ID,
+		Title:          entry.Title,
+		Series:         m.Series,
+		Episode:        &entry.Episode,
+		URL:            entry.URL,
+		PosterImageURL: entry.PosterImageURL,
+		Duration:       entry.Duration,
+	}
+
+	if entry.Duration <= 0 {
+		result, err := prober.Probe(context.Background(), entry.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		media.Duration = result.Duration
+		media.Width = result.Width
+		media.Height = result.Height
+		media.Codecs = result.Codecs
+	}
+
+	return media, nil
+}
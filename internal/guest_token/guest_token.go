@@ -0,0 +1,97 @@
+package guest_token
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformedToken = errors.New("guest token is malformed")
+	ErrInvalidToken   = errors.New("guest token signature is invalid")
+	ErrExpiredToken   = errors.New("guest token has expired")
+)
+
+// Claims is the identity carried inside a guest token.
+type Claims struct {
+	GuestID  string `json:"gid"`
+	Username string `json:"usr"`
+	IssuedAt int64  `json:"iat"`
+}
+
+type Signer struct {
+	secret []byte
+	maxAge time.Duration
+}
+
+// NewSigner creates a signer for the given secret.
+// When no secret is given, a random one is generated, meaning tokens will not survive a restart.
+// A maxAge of 0 means tokens never expire.
+func NewSigner(secret []byte, maxAge time.Duration) *Signer {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+
+	return &Signer{
+		secret: secret,
+		maxAge: maxAge,
+	}
+}
+
+func (s *Signer) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Sign issues a token for the given guest id and username.
+func (s *Signer) Sign(guestId string, username string) (string, error) {
+	data, err := json.Marshal(Claims{
+		GuestID:  guestId,
+		Username: username,
+		IssuedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + s.sign(payload), nil
+}
+
+// Verify checks the token signature and age, returning the claims it carries.
+func (s *Signer) Verify(token string) (*Claims, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrMalformedToken
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return nil, ErrInvalidToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(data, &claims); err != nil || claims.GuestID == "" {
+		return nil, ErrMalformedToken
+	}
+
+	if s.maxAge > 0 && time.Since(time.Unix(claims.IssuedAt, 0)) > s.maxAge {
+		return nil, ErrExpiredToken
+	}
+
+	return &claims, nil
+}
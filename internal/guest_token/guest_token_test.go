@@ -0,0 +1,66 @@
+package guest_token
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Signs the claims as given, so tests can issue tokens with any issue time.
+func tokenFor(s *Signer, claims Claims) string {
+	data, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + s.sign(payload)
+}
+
+func TestVerify(t *testing.T) {
+	signer := NewSigner([]byte("secret"), time.Hour)
+	other := NewSigner([]byte("other"), time.Hour)
+
+	valid, err := signer.Sign("guest", "Guest")
+	if err != nil {
+		t.Fatalf("Sign returned error: %v", err)
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte("not json"))
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "valid", token: valid},
+		{name: "no signature", token: "payload", wantErr: ErrMalformedToken},
+		{name: "tampered signature", token: valid + "x", wantErr: ErrInvalidToken},
+		{name: "other secret", token: tokenFor(other, Claims{GuestID: "guest", IssuedAt: time.Now().Unix()}), wantErr: ErrInvalidToken},
+		{name: "tampered payload", token: tokenFor(signer, Claims{GuestID: "guest"})[1:], wantErr: ErrInvalidToken},
+		{name: "expired", token: tokenFor(signer, Claims{GuestID: "guest", IssuedAt: time.Now().Add(-2 * time.Hour).Unix()}), wantErr: ErrExpiredToken},
+		{name: "payload isn't json", token: payload + "." + signer.sign(payload), wantErr: ErrMalformedToken},
+		{name: "payload isn't base64", token: "!!." + signer.sign("!!"), wantErr: ErrMalformedToken},
+		{name: "no guest id", token: tokenFor(signer, Claims{Username: "Guest", IssuedAt: time.Now().Unix()}), wantErr: ErrMalformedToken},
+	}
+
+	for _, tt := range tests {
+		claims, err := signer.Verify(tt.token)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Verify returned error %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+
+		if tt.wantErr == nil && (claims.GuestID != "guest" || claims.Username != "Guest") {
+			t.Errorf("%s: Verify returned claims %+v", tt.name, claims)
+		}
+	}
+}
+
+func TestVerifyWithoutMaxAge(t *testing.T) {
+	signer := NewSigner([]byte("secret"), 0)
+
+	token := tokenFor(signer, Claims{GuestID: "guest", IssuedAt: time.Now().AddDate(-10, 0, 0).Unix()})
+	if _, err := signer.Verify(token); err != nil {
+		t.Errorf("Verify returned error for an old token without a max age: %v", err)
+	}
+}
@@ -1,14 +1,38 @@
 package m3u8_duration
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"net/http"
 	"net/url"
 	"path"
+	"time"
 
 	"github.com/etherlabsio/go-m3u8/m3u8"
 )
 
+// Fetch reads the playlist at the URL.
+type Fetch func(ctx context.Context, url string) ([]byte, error)
+
+// DateRange is an EXT-X-DATERANGE tag, placed on the media timeline in seconds.
+type DateRange struct {
+	ID    string
+	Class string
+	Start float64
+	// The end of the range, nil when the playlist doesn't say how long it lasts.
+	End *float64
+}
+
+type Playlist struct {
+	Duration   float64
+	DateRanges []DateRange
+
+	// The resolution and codecs of the followed variant, when the master playlist lists them.
+	Width  int
+	Height int
+	Codecs string
+}
+
 func cleanURL(u string) string {
 	parsedUrl, _ := url.Parse(u)
 	parsedUrl.Path = path.Dir(parsedUrl.Path)
@@ -16,16 +40,16 @@ func cleanURL(u string) string {
 	return parsedUrl.String()
 }
 
-func FetchM3u8Duration(url string) (float64, error) {
-	resp, err := http.Get(url)
+// FetchM3u8 fetches the media playlist, following the first variant of a master playlist.
+func FetchM3u8(ctx context.Context, fetch Fetch, url string) (*Playlist, error) {
+	body, err := fetch(ctx, url)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	playlist, err := m3u8.Read(resp.Body)
+	playlist, err := m3u8.Read(bytes.NewReader(body))
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	if playlist.IsMaster() {
@@ -35,10 +59,90 @@ func FetchM3u8Duration(url string) (float64, error) {
 				item := item.(*m3u8.PlaylistItem)
 
 				uri := fmt.Sprintf("%s/%s", cleanURL(url), item.URI)
-				return FetchM3u8Duration(uri)
+				variant, err := FetchM3u8(ctx, fetch, uri)
+				if err != nil {
+					return nil, err
+				}
+
+				if item.Resolution != nil {
+					variant.Width = item.Resolution.Width
+					variant.Height = item.Resolution.Height
+				}
+
+				if item.Codecs != nil {
+					variant.Codecs = *item.Codecs
+				}
+
+				return variant, nil
+			}
+		}
+	}
+
+	return &Playlist{
+		Duration:   playlist.Duration(),
+		DateRanges: dateRanges(playlist),
+	}, nil
+}
+
+// Places the date ranges of the playlist on the media timeline, using EXT-X-PROGRAM-DATE-TIME
+// to map their start dates. Ranges that can't be placed are left out.
+func dateRanges(playlist *m3u8.Playlist) []DateRange {
+	ranges := make([]DateRange, 0)
+
+	var (
+		anchor      time.Time
+		anchorMedia float64
+		elapsed     float64
+	)
+
+	for _, item := range playlist.Items {
+		switch item := item.(type) {
+		case *m3u8.TimeItem:
+			anchor = item.Time
+			anchorMedia = elapsed
+		case *m3u8.SegmentItem:
+			if item.ProgramDateTime != nil {
+				anchor = item.ProgramDateTime.Time
+				anchorMedia = elapsed
+			}
+
+			elapsed += item.Duration
+		case *m3u8.DateRangeItem:
+			if anchor.IsZero() {
+				continue
+			}
+
+			startDate, err := m3u8.ParseTime(item.StartDate)
+			if err != nil {
+				continue
 			}
+
+			r := DateRange{
+				ID:    item.ID,
+				Start: anchorMedia + startDate.Sub(anchor).Seconds(),
+			}
+
+			if item.Class != nil {
+				r.Class = *item.Class
+			}
+
+			switch {
+			case item.EndDate != nil:
+				if endDate, err := m3u8.ParseTime(*item.EndDate); err == nil {
+					end := anchorMedia + endDate.Sub(anchor).Seconds()
+					r.End = &end
+				}
+			case item.Duration != nil:
+				end := r.Start + *item.Duration
+				r.End = &end
+			case item.PlannedDuration != nil:
+				end := r.Start + *item.PlannedDuration
+				r.End = &end
+			}
+
+			ranges = append(ranges, r)
 		}
 	}
 
-	return playlist.Duration(), nil
+	return ranges
 }
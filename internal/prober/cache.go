@@ -0,0 +1,213 @@
+package prober
+
+import (
+	"container/list"
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// How long a probe result is reused for, 0 disables the cache.
+	CacheTTL = 10 * time.Minute
+	// The max amount of probe results kept, the least recently used are evicted first.
+	CacheSize = 1000
+)
+
+// CacheStats counts how often probes were answered from the cache.
+type CacheStats struct {
+	// Probes answered from the cache.
+	Hits uint64 `json:"hits"`
+	// Probes that had to fetch the media.
+	Misses uint64 `json:"misses"`
+	// Probes that waited on a fetch of the same media that was already running.
+	Shared uint64 `json:"shared"`
+	// The amount of results currently cached.
+	Size int `json:"size"`
+}
+
+type cacheEntry struct {
+	key     string
+	result  *Result
+	expires time.Time
+}
+
+// A fetch that other probes of the same media wait on.
+type inflight struct {
+	done   chan struct{}
+	result *Result
+	err    error
+}
+
+type resultCache struct {
+	mu sync.Mutex
+
+	entries map[string]*list.Element
+	// Cache entries ordered from most to least recently used.
+	order   *list.List
+	fetches map[string]*inflight
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	shared atomic.Uint64
+}
+
+var cache = &resultCache{
+	entries: make(map[string]*list.Element),
+	order:   list.New(),
+	fetches: make(map[string]*inflight),
+}
+
+// Stats returns the cache's hit and miss counters.
+func Stats() CacheStats {
+	cache.mu.Lock()
+	size := cache.order.Len()
+	cache.mu.Unlock()
+
+	return CacheStats{
+		Hits:   cache.hits.Load(),
+		Misses: cache.misses.Load(),
+		Shared: cache.shared.Load(),
+		Size:   size,
+	}
+}
+
+// Normalises the URL so the same media is cached once, however its scheme and host were written.
+// The scheme and host are lowercased, default ports are dropped and so is the fragment, which never
+// reaches the origin. The path and query are kept byte for byte, since the origin may treat URLs
+// that only differ there as different media.
+func cacheKey(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+
+	_, rest, ok := strings.Cut(u, "://")
+	if !ok || parsed.Host == "" {
+		return u
+	}
+
+	host := strings.ToLower(parsed.Host)
+	scheme := strings.ToLower(parsed.Scheme)
+	if port := parsed.Port(); (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		host = strings.TrimSuffix(host, ":"+port)
+	}
+
+	if parsed.User != nil {
+		host = parsed.User.String() + "@" + host
+	}
+
+	rest, _, _ = strings.Cut(rest, "#")
+	if i := strings.IndexAny(rest, "/?"); i != -1 {
+		rest = rest[i:]
+	} else {
+		rest = ""
+	}
+
+	return scheme + "://" + host + rest
+}
+
+// Returns the cached result for the media, fetching it with probe when there is none.
+// Probes of media that is already being fetched wait on that fetch instead of starting another.
+func (rc *resultCache) get(ctx context.Context, u string, probe func(ctx context.Context) (*Result, error)) (*Result, error) {
+	if CacheTTL <= 0 || CacheSize <= 0 {
+		rc.misses.Add(1)
+		return probe(ctx)
+	}
+
+	key := cacheKey(u)
+
+	rc.mu.Lock()
+
+	if e, ok := rc.entries[key]; ok {
+		entry := e.Value.(*cacheEntry)
+		if time.Now().Before(entry.expires) {
+			rc.order.MoveToFront(e)
+			rc.mu.Unlock()
+
+			rc.hits.Add(1)
+			return entry.result.clone(), nil
+		}
+
+		rc.order.Remove(e)
+		delete(rc.entries, key)
+	}
+
+	if f, ok := rc.fetches[key]; ok {
+		rc.mu.Unlock()
+		rc.shared.Add(1)
+
+		select {
+		case <-f.done:
+			if f.err != nil {
+				return nil, f.err
+			}
+
+			return f.result.clone(), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	f := &inflight{
+		done: make(chan struct{}),
+	}
+	rc.fetches[key] = f
+	rc.mu.Unlock()
+
+	rc.misses.Add(1)
+
+	// Other probes may be waiting on this fetch, so it isn't cut short when this probe is cancelled.
+	go func() {
+		f.result, f.err = probe(context.WithoutCancel(ctx))
+
+		rc.mu.Lock()
+		delete(rc.fetches, key)
+		if f.err == nil {
+			rc.store(key, f.result)
+		}
+		rc.mu.Unlock()
+
+		close(f.done)
+	}()
+
+	select {
+	case <-f.done:
+		if f.err != nil {
+			return nil, f.err
+		}
+
+		return f.result.clone(), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Caches the result, evicting the least recently used results once the cache is full.
+// The lock must be held by the caller.
+func (rc *resultCache) store(key string, result *Result) {
+	rc.entries[key] = rc.order.PushFront(&cacheEntry{
+		key:     key,
+		result:  result,
+		expires: time.Now().Add(CacheTTL),
+	})
+
+	for rc.order.Len() > CacheSize {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Copies the result, so callers can't change what is cached.
+func (r *Result) clone() *Result {
+	c := *r
+	c.Codecs = slices.Clone(r.Codecs)
+	c.DateRanges = slices.Clone(r.DateRanges)
+
+	return &c
+}
@@ -0,0 +1,147 @@
+package prober
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidDuration = errors.New("manifest duration is not a valid ISO 8601 duration")
+
+type dashProber struct{}
+
+type mpd struct {
+	MediaPresentationDuration string `xml:"mediaPresentationDuration,attr"`
+	Periods                   []struct {
+		Duration       string `xml:"duration,attr"`
+		AdaptationSets []struct {
+			MimeType        string `xml:"mimeType,attr"`
+			ContentType     string `xml:"contentType,attr"`
+			Codecs          string `xml:"codecs,attr"`
+			Representations []struct {
+				MimeType  string `xml:"mimeType,attr"`
+				Codecs    string `xml:"codecs,attr"`
+				Bandwidth int    `xml:"bandwidth,attr"`
+				Width     int    `xml:"width,attr"`
+				Height    int    `xml:"height,attr"`
+			} `xml:"Representation"`
+		} `xml:"AdaptationSet"`
+	} `xml:"Period"`
+}
+
+func (dashProber) Probe(ctx context.Context, url string) (*Result, error) {
+	body, err := fetchManifest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest mpd
+	if err := xml.Unmarshal(body, &manifest); err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+
+	if manifest.MediaPresentationDuration != "" {
+		result.Duration, err = parseISODuration(manifest.MediaPresentationDuration)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, period := range manifest.Periods {
+			if period.Duration == "" {
+				continue
+			}
+
+			d, err := parseISODuration(period.Duration)
+			if err != nil {
+				return nil, err
+			}
+
+			result.Duration += d
+		}
+	}
+
+	if result.Duration <= 0 {
+		return nil, ErrNoDuration
+	}
+
+	// The stream info is taken from the first period, using the highest bandwidth video representation.
+	if len(manifest.Periods) == 0 {
+		return result, nil
+	}
+
+	bandwidth := -1
+	seen := make(map[string]bool)
+
+	for _, set := range manifest.Periods[0].AdaptationSets {
+		for _, rep := range set.Representations {
+			codecs := rep.Codecs
+			if codecs == "" {
+				codecs = set.Codecs
+			}
+
+			if codecs != "" && !seen[codecs] {
+				seen[codecs] = true
+				result.Codecs = append(result.Codecs, codecs)
+			}
+
+			mimeType := rep.MimeType
+			if mimeType == "" {
+				mimeType = set.MimeType
+			}
+
+			video := strings.HasPrefix(mimeType, "video/") || set.ContentType == "video" || rep.Width > 0
+			if video && rep.Bandwidth > bandwidth {
+				bandwidth = rep.Bandwidth
+				result.Width = rep.Width
+				result.Height = rep.Height
+			}
+		}
+	}
+
+	return result, nil
+}
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)Y)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// Parses an ISO 8601 duration such as PT1H23M4.5S into seconds.
+// Years and months have no fixed length, so only zero values are accepted for them,
+// which some packagers write out in full (e.g. P0Y0M0DT0H3M30S).
+func parseISODuration(s string) (float64, error) {
+	match := isoDuration.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return 0, ErrInvalidDuration
+	}
+
+	for _, calendar := range match[1:3] {
+		if calendar == "" {
+			continue
+		}
+
+		if v, err := strconv.ParseFloat(calendar, 64); err != nil || v != 0 {
+			return 0, ErrInvalidDuration
+		}
+	}
+
+	units := []float64{24 * 60 * 60, 60 * 60, 60, 1}
+
+	var seconds float64
+	for i, unit := range units {
+		if match[i+3] == "" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(match[i+3], 64)
+		if err != nil {
+			return 0, ErrInvalidDuration
+		}
+
+		seconds += v * unit
+	}
+
+	return seconds, nil
+}
@@ -0,0 +1,44 @@
+package prober
+
+import "testing"
+
+func TestParseISODuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{in: "PT1H23M4.5S", want: 4984.5},
+		{in: "PT30S", want: 30},
+		{in: "PT0.5S", want: 0.5},
+		{in: "P1DT2H", want: 93600},
+		{in: "P0Y0M0DT0H3M30S", want: 210},
+		{in: "P0Y0M1D", want: 86400},
+		{in: " PT10M ", want: 600},
+		{in: "P1Y", wantErr: true},
+		{in: "P0Y2MT1S", wantErr: true},
+		{in: "PT1H30", wantErr: true},
+		{in: "1H30M", wantErr: true},
+		{in: "PT-5S", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseISODuration(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseISODuration(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseISODuration(%q) returned error: %v", tt.in, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("parseISODuration(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package prober
+
+import (
+	"context"
+	"strings"
+
+	"github.com/MinnaSync/minna-sync-backend/internal/m3u8_duration"
+)
+
+type hlsProber struct{}
+
+func (hlsProber) Probe(ctx context.Context, url string) (*Result, error) {
+	playlist, err := m3u8_duration.FetchM3u8(ctx, fetchManifest, url)
+	if err != nil {
+		return nil, err
+	}
+
+	// Playlists without segments have nothing to play, so they would end as soon as they start.
+	if playlist.Duration <= 0 {
+		return nil, ErrNoDuration
+	}
+
+	result := &Result{
+		Duration:   playlist.Duration,
+		Width:      playlist.Width,
+		Height:     playlist.Height,
+		DateRanges: playlist.DateRanges,
+	}
+
+	if playlist.Codecs != "" {
+		for _, codec := range strings.Split(playlist.Codecs, ",") {
+			result.Codecs = append(result.Codecs, strings.TrimSpace(codec))
+		}
+	}
+
+	return result, nil
+}
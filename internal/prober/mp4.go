@@ -0,0 +1,241 @@
+package prober
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var (
+	// The largest moov box that will be downloaded.
+	MaxMoovSize int64 = 16 << 20
+	// How many top level boxes are walked looking for the moov box before giving up.
+	MaxTopLevelBoxes = 64
+)
+
+var (
+	ErrRangeNotSupported = errors.New("server does not support range requests")
+	ErrNoMoov            = errors.New("media has no moov box")
+	ErrMoovTooLarge      = errors.New("moov box is too large")
+	ErrMalformedBox      = errors.New("media has a malformed box")
+)
+
+type mp4Prober struct{}
+
+// Walks the top level boxes with ranged reads until the moov box is found, so only the
+// box headers and the moov box itself are downloaded.
+func (mp4Prober) Probe(ctx context.Context, url string) (*Result, error) {
+	var offset int64
+
+	for range MaxTopLevelBoxes {
+		header, err := readRange(ctx, url, offset, 16)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(header) < 8 {
+			break
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		typ := string(header[4:8])
+		headerSize := int64(8)
+
+		switch size {
+		case 0:
+			// The box runs until the end of the file, so nothing can come after it.
+			if typ != "moov" {
+				return nil, ErrNoMoov
+			}
+			size = MaxMoovSize
+		case 1:
+			if len(header) < 16 {
+				return nil, ErrMalformedBox
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+
+		if size < headerSize {
+			return nil, ErrMalformedBox
+		}
+
+		if typ == "moov" {
+			if size > MaxMoovSize {
+				return nil, ErrMoovTooLarge
+			}
+
+			moov, err := readRange(ctx, url, offset+headerSize, size-headerSize)
+			if err != nil {
+				return nil, err
+			}
+
+			return parseMoov(moov)
+		}
+
+		offset += size
+	}
+
+	return nil, ErrNoMoov
+}
+
+// Reads length bytes at the offset, returning fewer when the file ends before that.
+func readRange(ctx context.Context, url string, offset, length int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, nil
+	case http.StatusOK:
+		// The whole file is being sent, which is only usable when reading from the start.
+		if offset > 0 {
+			return nil, ErrRangeNotSupported
+		}
+	default:
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, length))
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Calls fn with the type and payload of every box in data.
+func walkBoxes(data []byte, fn func(typ string, payload []byte) error) error {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		typ := string(data[4:8])
+		headerSize := uint64(8)
+
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return ErrMalformedBox
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerSize = 16
+		}
+
+		if size < headerSize || size > uint64(len(data)) {
+			return ErrMalformedBox
+		}
+
+		if err := fn(typ, data[headerSize:size]); err != nil {
+			return err
+		}
+
+		data = data[size:]
+	}
+
+	return nil
+}
+
+func parseMoov(moov []byte) (*Result, error) {
+	result := &Result{}
+
+	err := walkBoxes(moov, func(typ string, payload []byte) error {
+		switch typ {
+		case "mvhd":
+			duration, err := parseMvhd(payload)
+			if err != nil {
+				return err
+			}
+			result.Duration = duration
+		case "trak":
+			return parseTrak(payload, result)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Duration <= 0 {
+		return nil, ErrNoDuration
+	}
+
+	return result, nil
+}
+
+// Reads the duration in seconds from a movie header box.
+func parseMvhd(payload []byte) (float64, error) {
+	if len(payload) < 4 {
+		return 0, ErrMalformedBox
+	}
+
+	var timescale uint32
+	var duration uint64
+
+	switch payload[0] {
+	case 0:
+		if len(payload) < 20 {
+			return 0, ErrMalformedBox
+		}
+		timescale = binary.BigEndian.Uint32(payload[12:16])
+		duration = uint64(binary.BigEndian.Uint32(payload[16:20]))
+	case 1:
+		if len(payload) < 32 {
+			return 0, ErrMalformedBox
+		}
+		timescale = binary.BigEndian.Uint32(payload[20:24])
+		duration = binary.BigEndian.Uint64(payload[24:32])
+	default:
+		return 0, ErrMalformedBox
+	}
+
+	if timescale == 0 {
+		return 0, ErrMalformedBox
+	}
+
+	return float64(duration) / float64(timescale), nil
+}
+
+// Reads the resolution and codec of a track into the result.
+func parseTrak(trak []byte, result *Result) error {
+	return walkBoxes(trak, func(typ string, payload []byte) error {
+		switch typ {
+		case "tkhd":
+			// The width and height are 16.16 fixed point numbers at the end of the box.
+			if len(payload) < 8 {
+				return ErrMalformedBox
+			}
+			width := int(binary.BigEndian.Uint32(payload[len(payload)-8:]) >> 16)
+			height := int(binary.BigEndian.Uint32(payload[len(payload)-4:]) >> 16)
+
+			if width > 0 && height > 0 && result.Width == 0 {
+				result.Width = width
+				result.Height = height
+			}
+		case "mdia", "minf", "stbl":
+			return parseTrak(payload, result)
+		case "stsd":
+			// Skips the version, flags and entry count to get to the first sample entry.
+			if len(payload) < 16 {
+				return ErrMalformedBox
+			}
+			result.Codecs = append(result.Codecs, string(payload[12:16]))
+		}
+
+		return nil
+	})
+}
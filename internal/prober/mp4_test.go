@@ -0,0 +1,76 @@
+package prober
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// Builds an mvhd payload, leaving the fields that aren't read zeroed.
+func mvhd(version byte, timescale uint32, duration uint64) []byte {
+	if version == 1 {
+		payload := make([]byte, 112)
+		payload[0] = 1
+		binary.BigEndian.PutUint32(payload[20:24], timescale)
+		binary.BigEndian.PutUint64(payload[24:32], duration)
+		return payload
+	}
+
+	payload := make([]byte, 100)
+	binary.BigEndian.PutUint32(payload[12:16], timescale)
+	binary.BigEndian.PutUint32(payload[16:20], uint32(duration))
+	return payload
+}
+
+func TestParseMvhd(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    float64
+		wantErr bool
+	}{
+		{name: "version 0", payload: mvhd(0, 1000, 90500), want: 90.5},
+		{name: "version 1", payload: mvhd(1, 90000, 1<<33), want: float64(uint64(1)<<33) / 90000},
+		{name: "zero timescale", payload: mvhd(0, 0, 100), wantErr: true},
+		{name: "unknown version", payload: append([]byte{2}, mvhd(0, 1000, 1000)[1:]...), wantErr: true},
+		{name: "truncated version 0", payload: mvhd(0, 1000, 1000)[:16], wantErr: true},
+		{name: "truncated version 1", payload: mvhd(1, 1000, 1000)[:28], wantErr: true},
+		{name: "empty", payload: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseMvhd(tt.payload)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: parseMvhd() = %v, want an error", tt.name, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: parseMvhd() returned error: %v", tt.name, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("%s: parseMvhd() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWalkBoxesRejectsMalformedSizes(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "size past the end", data: []byte{0, 0, 0, 16, 'm', 'v', 'h', 'd'}},
+		{name: "size smaller than header", data: []byte{0, 0, 0, 4, 'm', 'v', 'h', 'd'}},
+		{name: "truncated large size", data: []byte{0, 0, 0, 1, 'm', 'v', 'h', 'd', 0, 0}},
+	}
+
+	for _, tt := range tests {
+		err := walkBoxes(tt.data, func(string, []byte) error { return nil })
+		if err != ErrMalformedBox {
+			t.Errorf("%s: walkBoxes() = %v, want %v", tt.name, err, ErrMalformedBox)
+		}
+	}
+}
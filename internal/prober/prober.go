@@ -0,0 +1,162 @@
+package prober
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/MinnaSync/minna-sync-backend/internal/m3u8_duration"
+)
+
+var (
+	// How long a single probe can take, including every request it makes.
+	Timeout = 15 * time.Second
+	// How many redirects are followed for each request.
+	MaxRedirects = 5
+	// The largest manifest or playlist that will be read.
+	MaxManifestSize int64 = 4 << 20
+)
+
+var (
+	ErrNoDuration       = errors.New("media duration could not be determined")
+	ErrTooManyRedirects = errors.New("media redirected too many times")
+	ErrManifestTooLarge = errors.New("manifest is too large")
+)
+
+var client = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= MaxRedirects {
+			return ErrTooManyRedirects
+		}
+
+		return nil
+	},
+}
+
+// Result is what a prober could find out about a piece of media.
+type Result struct {
+	// The duration in seconds.
+	Duration float64
+	// The resolution of the video, 0 when it isn't known.
+	Width  int
+	Height int
+	// The codecs of the media, as listed in the manifest or sample descriptions.
+	Codecs []string
+	// Date ranges found in HLS playlists, which can be turned into chapter markers.
+	DateRanges []m3u8_duration.DateRange
+}
+
+// MediaProber reads the duration and stream info of media at a URL.
+type MediaProber interface {
+	Probe(ctx context.Context, url string) (*Result, error)
+}
+
+var (
+	HLS  MediaProber = hlsProber{}
+	DASH MediaProber = dashProber{}
+	MP4  MediaProber = mp4Prober{}
+)
+
+var extensions = map[string]MediaProber{
+	".m3u8": HLS,
+	".m3u":  HLS,
+	".mpd":  DASH,
+	".mp4":  MP4,
+	".m4v":  MP4,
+	".mov":  MP4,
+}
+
+var contentTypes = map[string]MediaProber{
+	"application/vnd.apple.mpegurl": HLS,
+	"application/x-mpegurl":         HLS,
+	"audio/mpegurl":                 HLS,
+	"audio/x-mpegurl":               HLS,
+	"application/dash+xml":          DASH,
+	"video/mp4":                     MP4,
+	"video/quicktime":               MP4,
+	"video/x-m4v":                   MP4,
+}
+
+// For picks the prober for the URL from its extension, asking the server for its content type
+// when the extension doesn't say. HLS is assumed when neither does.
+func For(ctx context.Context, u string) (MediaProber, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if p, ok := extensions[strings.ToLower(path.Ext(parsed.Path))]; ok {
+		return p, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if p, ok := contentTypes[strings.ToLower(contentType)]; ok {
+		return p, nil
+	}
+
+	return HLS, nil
+}
+
+// Probe probes the media with the prober picked for its URL, giving up after Timeout.
+// Results are cached for CacheTTL, and concurrent probes of the same media share a single fetch.
+func Probe(ctx context.Context, url string) (*Result, error) {
+	return cache.get(ctx, url, func(ctx context.Context) (*Result, error) {
+		ctx, cancel := context.WithTimeout(ctx, Timeout)
+		defer cancel()
+
+		p, err := For(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+
+		return p.Probe(ctx, url)
+	})
+}
+
+// Fetches a manifest or playlist, failing on unsuccessful responses and ones over MaxManifestSize.
+func fetchManifest(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// Reads one byte past the limit to tell a manifest that fits exactly from one that doesn't.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxManifestSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > MaxManifestSize {
+		return nil, ErrManifestTooLarge
+	}
+
+	return body, nil
+}
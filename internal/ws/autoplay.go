@@ -0,0 +1,43 @@
+package ws
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// NextEpisodeProvider finds the episode that follows the given media.
+type NextEpisodeProvider interface {
+	// NextEpisode returns nil when there is no following episode.
+	NextEpisode(m Media) (*Media, error)
+}
+
+// The provider used to autoplay the next episode once a channel's queue runs dry, nil disables autoplay.
+var NextEpisodes NextEpisodeProvider
+
+// Plays the episode following the media that just ended, finishing playback if there is none.
+func (c *Channel) autoplay(ended *NowPlayingMedia) {
+	// The provider may need to probe the media, so the lock is not held while it runs.
+	next, err := NextEpisodes.NextEpisode(ended.Media)
+	if err != nil {
+		log.WithError(err).Debug("Failed to find the next episode to autoplay.")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Something else was started while looking up the next episode.
+	if c.Playing != ended {
+		return
+	}
+
+	// Something was queued while looking up the next episode.
+	if c.playNext() {
+		return
+	}
+
+	if next == nil {
+		c.finish()
+		return
+	}
+
+	c.play(newEntry(*next))
+}
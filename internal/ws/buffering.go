@@ -0,0 +1,115 @@
+package ws
+
+import (
+	"time"
+)
+
+// How long a channel waits on buffering members before resuming without them.
+var MaxBufferWait = 15 * time.Second
+
+// SetBuffering records whether the client's player is buffering, pausing the channel
+// until everyone has caught up when the channel waits for all members.
+func (c *Channel) SetBuffering(sender *Client, buffering bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Playing == nil || !c.Mode.WaitForAll || sender.Spectator {
+		return
+	}
+
+	if buffering == c.buffering[sender] {
+		return
+	}
+
+	if buffering {
+		c.buffering[sender] = true
+	} else {
+		delete(c.buffering, sender)
+	}
+
+	c.checkBuffering()
+}
+
+// Stops waiting on a client, such as when it leaves the channel.
+func (c *Channel) stopWaitingFor(client *Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.buffering[client] {
+		return
+	}
+
+	delete(c.buffering, client)
+	c.checkBuffering()
+}
+
+// Pauses the channel while any member is buffering, and resumes it once everyone is ready.
+// The lock must be held by the caller.
+func (c *Channel) checkBuffering() {
+	if c.Playing == nil {
+		return
+	}
+
+	if len(c.buffering) != 0 {
+		if !c.Playing.Paused {
+			c.Playing.setPaused(true)
+			c.bufferPaused = true
+
+			playing := c.Playing
+			c.bufferTimer = time.AfterFunc(MaxBufferWait, func() {
+				c.mu.Lock()
+				defer c.mu.Unlock()
+
+				// Gives up on the members that are still buffering.
+				if c.Playing == playing && c.bufferPaused {
+					clear(c.buffering)
+					c.checkBuffering()
+				}
+			})
+
+			c.Emit("state_updated", c.playbackState())
+			c.reschedule()
+		}
+
+		c.emitWaitingFor()
+		return
+	}
+
+	c.emitWaitingFor()
+
+	if c.bufferPaused {
+		c.resetBuffering()
+
+		c.Playing.setPaused(false)
+		c.Emit("state_updated", c.playbackState())
+		c.lastSync = time.Now()
+		c.reschedule()
+	}
+}
+
+// Forgets about buffering members, used when the media changes or the controller takes over.
+// The lock must be held by the caller.
+func (c *Channel) resetBuffering() {
+	clear(c.buffering)
+	c.bufferPaused = false
+
+	if c.bufferTimer != nil {
+		c.bufferTimer.Stop()
+		c.bufferTimer = nil
+	}
+}
+
+// The lock must be held by the caller.
+func (c *Channel) emitWaitingFor() {
+	members := make([]Submitter, 0, len(c.buffering))
+	for client := range c.buffering {
+		members = append(members, Submitter{
+			ID:       client.User.ID,
+			Username: client.User.Username,
+		})
+	}
+
+	c.Emit("waiting_for", WaitingFor{
+		Members: members,
+	})
+}
@@ -1,19 +1,43 @@
 package ws
 
 import (
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"slices"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+
 	log "github.com/sirupsen/logrus"
 )
 
 var (
 	// The amount of messages that will be stored in-memory for a channel.
 	MaxStoredMessages = 100
-
-	channels = make(map[string]*Channel)
+	// The max amount of media that can be queued in a single batch.
+	MaxBatchSize = 50
+	// The amount of played media that will be remembered for a channel.
+	MaxHistory = 50
+
+	// The range the playback rate of a channel can be set within.
+	MinPlaybackRate = 0.25
+	MaxPlaybackRate = 2.0
+
+	// The max amount of media that can be queued in a channel, 0 is unlimited.
+	MaxQueueLength = 200
+	// The max amount of media a single member can have queued, 0 is unlimited.
+	MaxQueuedPerMember = 25
+	// The max combined duration of the queued media, 0 is unlimited.
+	MaxQueueDuration time.Duration = 0
+
+	ErrQueueFull            = errors.New("the queue is full")
+	ErrTooManyQueued        = errors.New("you have too much media queued")
+	ErrQueueDurationReached = errors.New("the queue is too long")
+
+	channels   = make(map[string]*Channel)
+	channelsMu sync.Mutex
 )
 
 type Channel struct {
@@ -26,6 +50,31 @@ type Channel struct {
 	Playing  *NowPlayingMedia
 	Queued   []Media
 	Messages []ChannelMessage
+	Mode     PlaybackMode
+	History  []HistoryEntry
+
+	// Members whose players are buffering, and whether the channel was paused to wait on them.
+	buffering    map[*Client]bool
+	bufferPaused bool
+	bufferTimer  *time.Timer
+
+	// Members that haven't loaded the newly started media during a ready check.
+	notReady   map[*Client]bool
+	readyTimer *time.Timer
+
+	// When the scheduler will next tick the channel, and when clients were last synced.
+	deadline time.Time
+	lastSync time.Time
+
+	// Whether the last media ended with nothing left to play, and whether the next episode is being looked up.
+	ended       bool
+	loadingNext bool
+
+	// When the playing media is scheduled to start, zero if it isn't.
+	scheduledStart time.Time
+
+	// Queue entries that are still being probed, by entry ID.
+	probes map[string]*pendingProbe
 
 	join  chan *Client
 	leave chan *Client
@@ -34,8 +83,12 @@ type Channel struct {
 }
 
 func JoinChannel(channelId string, client *Client) *Channel {
+	channelsMu.Lock()
+
+	// The lock is released before joining, since the channel may be closing and need it.
 	if c, exists := channels[channelId]; exists {
-		c.connections[client] = true
+		channelsMu.Unlock()
+
 		c.join <- client
 		return c
 	}
@@ -50,6 +103,14 @@ func JoinChannel(channelId string, client *Client) *Channel {
 		Playing:  nil,
 		Queued:   make([]Media, 0),
 		Messages: make([]ChannelMessage, 0, MaxStoredMessages),
+		History:  make([]HistoryEntry, 0, MaxHistory),
+		Mode: PlaybackMode{
+			Autoplay: true,
+		},
+
+		buffering: make(map[*Client]bool),
+		notReady:  make(map[*Client]bool),
+		probes:    make(map[string]*pendingProbe),
 
 		join:  make(chan *Client),
 		leave: make(chan *Client),
@@ -59,6 +120,8 @@ func JoinChannel(channelId string, client *Client) *Channel {
 
 	go channel.open()
 	channels[channelId] = channel
+	channelsMu.Unlock()
+
 	channel.join <- client
 
 	return channel
@@ -73,8 +136,10 @@ func (c *Channel) open() {
 				return
 			}
 
+			c.mu.Lock()
+
 			c.connections[client] = true
-			c.SendMessage(ChannelMessage{
+			c.sendMessage(ChannelMessage{
 				Type:     MessageTypeUserJoin,
 				UTCEpoch: time.Now().Unix(),
 				Username: "System",
@@ -87,8 +152,21 @@ func (c *Channel) open() {
 			if c.controller == nil {
 				c.controller = client
 			}
+
+			c.mu.Unlock()
 		case client := <-c.leave:
-			c.SendMessage(ChannelMessage{
+			c.stopWaitingFor(client)
+			c.skipReadyCheckFor(client)
+
+			c.mu.Lock()
+
+			// The client is removed before anything else is emitted, so nothing is sent on its closed channel.
+			if _, ok := c.connections[client]; ok {
+				delete(c.connections, client)
+				close(client.send)
+			}
+
+			c.sendMessage(ChannelMessage{
 				Type:     MessageTypeUserLeave,
 				UTCEpoch: time.Now().Unix(),
 				Username: "System",
@@ -98,30 +176,27 @@ func (c *Channel) open() {
 				),
 			})
 
-			if _, ok := c.connections[client]; ok {
-				delete(c.connections, client)
-				close(client.send)
-			}
-
 			// Removes the controller and selects a new one if the channel has clients.
 			// TODO: Add a timer for when the room should automatically close after inactivity.
 			if c.controller == client {
 				c.controller = nil
 
-				if len(c.connections) == 0 {
-					continue
-				}
-
 				for c := range c.connections {
 					c.Channel.controller = c
 					break
 				}
 			}
+
+			c.mu.Unlock()
 		case <-c.closed:
 			c.mu.Lock()
 			defer c.mu.Unlock()
 
+			c.cancelProbes()
+
+			channelsMu.Lock()
 			delete(channels, c.id)
+			channelsMu.Unlock()
 			return
 		}
 	}
@@ -143,7 +218,16 @@ func (c *Channel) Emit(event string, data any) {
 	}
 }
 
+// SendMessage stores the message in the channel's history and sends it to every member.
 func (c *Channel) SendMessage(message ChannelMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sendMessage(message)
+}
+
+// The lock must be held by the caller.
+func (c *Channel) sendMessage(message ChannelMessage) {
 	if len(c.Messages) >= MaxStoredMessages {
 		c.Messages = c.Messages[1:]
 	}
@@ -153,13 +237,16 @@ func (c *Channel) SendMessage(message ChannelMessage) {
 }
 
 func (c *Channel) PurgeMessages(sender *Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.Messages = make([]ChannelMessage, 0)
 
 	c.Emit("command", Command{
 		Type: CommandTypePurgeMessages,
 	})
 
-	c.SendMessage(ChannelMessage{
+	c.sendMessage(ChannelMessage{
 		Type:     MessageTypeNotification,
 		UTCEpoch: time.Now().Unix(),
 		Username: "System",
@@ -168,12 +255,15 @@ func (c *Channel) PurgeMessages(sender *Client) {
 }
 
 func (c *Channel) GrantControl(sender *Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.controller == sender {
 		return
 	}
 
 	c.controller = sender
-	c.SendMessage(ChannelMessage{
+	c.sendMessage(ChannelMessage{
 		Type:     MessageTypeNotification,
 		UTCEpoch: time.Now().Unix(),
 		Username: "System",
@@ -181,136 +271,531 @@ func (c *Channel) GrantControl(sender *Client) {
 	})
 }
 
-func (c *Channel) playback() {
-	for {
-		select {
-		case <-c.Playing.ticker.C:
-			currentPlaybackTime := c.Playing.CurrentPlaybackTime()
+// Starts playing the media, replacing whatever is currently playing.
+// The lock must be held by the caller.
+func (c *Channel) play(m Media) {
+	now := time.Now()
 
-			if currentPlaybackTime >= c.Playing.Duration-0.5 {
-				if len(c.Queued) != 0 {
-					c.QueueChange()
-					continue
-				}
+	c.resetBuffering()
+
+	// The playback rate carries over, so a room watching at 1.5x keeps doing so.
+	rate := 1.0
+	if c.Playing != nil {
+		rate = c.Playing.PlaybackRate
+	}
+
+	c.Playing = &NowPlayingMedia{
+		Media:        m,
+		Paused:       false,
+		CurrentTime:  0,
+		PlaybackRate: rate,
+		ServerTime:   now.UnixMilli(),
+
+		startedAt:  now,
+		lastChange: now,
+	}
+	c.lastSync = now
+
+	if c.Mode.ReadyCheck {
+		c.startReadyCheck()
+	} else {
+		c.stopReadyCheck()
+	}
+
+	c.ended = false
+	c.loadingNext = false
+	c.clearScheduledStart()
+
+	c.Emit("media_changed", c.nowPlaying())
+	c.sendMessage(ChannelMessage{
+		Type:     MessageTypeMediaChanged,
+		UTCEpoch: time.Now().Unix(),
+		Username: "System",
+		Content:  fmt.Sprintf("%s is now playing.", m.DisplayName()),
+	})
+
+	c.reschedule()
+}
+
+// Assigns the media a new queue entry ID and the time it was queued.
+func newEntry(m Media) Media {
+	m.EntryID = uuid.NewString()
+	m.QueuedAt = time.Now().Unix()
+
+	return m
+}
 
-				close(c.Playing.finished)
-				continue
+// Puts media that was playing back into the queue at the position, keeping the queue in fair order.
+// It is queued as a new entry, since the old one is still referenced by the history.
+// The lock must be held by the caller.
+func (c *Channel) requeue(m Media, position int) {
+	m = newEntry(m)
+
+	c.Queued = slices.Insert(c.Queued, position, m)
+	c.Emit("queue_updated", QueuedMedia{
+		Media:    m,
+		Position: position,
+	})
+
+	c.rebalance()
+}
+
+// Checks whether the media can be added to the queue without going over the queue limits.
+// The lock must be held by the caller.
+func (c *Channel) checkLimits(added ...Media) error {
+	if MaxQueueLength > 0 && len(c.Queued)+len(added) > MaxQueueLength {
+		return fmt.Errorf("%w, it can hold at most %d media", ErrQueueFull, MaxQueueLength)
+	}
+
+	if MaxQueuedPerMember > 0 {
+		queue := slices.Concat(c.Queued, added)
+
+		for _, m := range added {
+			pending := 0
+			for _, queued := range queue {
+				if submitterOf(queued) == submitterOf(m) {
+					pending++
+				}
 			}
 
-			if !c.Playing.Paused && (int64(currentPlaybackTime)%10) == 0 {
-				c.Emit("state_sync", PlaybackState{
-					Paused:      c.Playing.Paused,
-					CurrentTime: currentPlaybackTime,
-				})
+			if pending > MaxQueuedPerMember {
+				return fmt.Errorf("%w, each member can queue at most %d media", ErrTooManyQueued, MaxQueuedPerMember)
 			}
-		case <-c.Playing.finished:
-			c.Playing.ticker.Stop()
-			c.Playing = nil
-			return
 		}
 	}
+
+	return c.checkDuration(added...)
 }
 
-func (c *Channel) QueueInsert(m Media) {
+// Checks whether the media fits in the queue without going over its max duration.
+// Media that is already queued is only counted once, so it can be rechecked once it has been probed.
+// The lock must be held by the caller.
+func (c *Channel) checkDuration(added ...Media) error {
+	if MaxQueueDuration <= 0 {
+		return nil
+	}
+
+	var total float64
+	for _, m := range added {
+		total += m.Duration
+	}
+
+	for _, queued := range c.Queued {
+		if !slices.ContainsFunc(added, func(m Media) bool { return m.EntryID == queued.EntryID }) {
+			total += queued.Duration
+		}
+	}
+
+	if total > MaxQueueDuration.Seconds() {
+		return fmt.Errorf("%w, it can hold at most %s of media", ErrQueueDurationReached, MaxQueueDuration)
+	}
+
+	return nil
+}
+
+func (c *Channel) QueueInsert(sender *Client, m Media, opts QueueOptions) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.Playing != nil {
-		c.Queued = append(c.Queued, m)
-		c.Emit("queue_updated", m)
+	m = newEntry(m)
+	m.Probing = Prober != nil
+
+	// Checks the limits against everything that ends up in the queue, including media displaced by playing now.
+	added := make([]Media, 0, 2)
+	if m.Probing || (c.Playing != nil && !opts.PlayNow) {
+		added = append(added, m)
+	}
+
+	if c.Playing != nil && opts.PlayNow {
+		added = append(added, c.Playing.Media)
+	}
+
+	if err := c.checkLimits(added...); err != nil {
+		return err
+	}
 
-		c.SendMessage(ChannelMessage{
-			Type:     MessageTypeMediaQueued,
-			UTCEpoch: time.Now().Unix(),
-			Username: "System",
-			Content:  fmt.Sprintf("%s - %s has been added to the queue.", *m.Title, *m.Series),
+	// Media being probed waits at the front of the queue, and starts once the probe finishes.
+	if m.Probing && opts.PlayNow {
+		c.Queued = slices.Insert(c.Queued, 0, m)
+		c.Emit("queue_updated", QueuedMedia{
+			Media:    m,
+			Position: 0,
 		})
 
-		return
+		c.startProbe(m, &pendingProbe{
+			sender:  sender,
+			event:   "queue_media",
+			playNow: true,
+		})
+		return nil
 	}
 
-	c.Playing = &NowPlayingMedia{
-		Media:       m,
-		Paused:      false,
-		CurrentTime: 0,
+	if !m.Probing && (c.Playing == nil || opts.PlayNow) {
+		if c.Playing != nil {
+			c.requeue(c.Playing.Media, 0)
+		}
 
-		lastChange: time.Now(),
-		ticker:     time.NewTicker(1 * time.Second),
-		finished:   make(chan bool),
+		c.play(m)
+		return nil
 	}
 
-	c.Emit("media_changed", c.Playing)
-	c.SendMessage(ChannelMessage{
-		Type:     MessageTypeMediaChanged,
+	position := opts.Position
+	if position < 0 || position > len(c.Queued) {
+		position = len(c.Queued)
+	}
+
+	// Fair mode decides the position, so the requested one is ignored.
+	if c.Mode.Fair {
+		position = c.insertFair(m)
+	} else {
+		c.Queued = slices.Insert(c.Queued, position, m)
+	}
+
+	c.Emit("queue_updated", QueuedMedia{
+		Media:    m,
+		Position: position,
+	})
+
+	content := fmt.Sprintf("%s has been added to the queue.", m.DisplayName())
+	if position != len(c.Queued)-1 {
+		content = fmt.Sprintf("%s has been added to position %d in the queue.", m.DisplayName(), position+1)
+	}
+
+	c.sendMessage(ChannelMessage{
+		Type:     MessageTypeMediaQueued,
 		UTCEpoch: time.Now().Unix(),
 		Username: "System",
-		Content:  fmt.Sprintf("%s - %s is now playing.", *m.Title, *m.Series),
+		Content:  content,
 	})
 
-	go c.playback()
+	if m.Probing {
+		c.startProbe(m, &pendingProbe{
+			sender: sender,
+			event:  "queue_media",
+		})
+	}
+
+	return nil
 }
 
-func (c *Channel) QueueRemove(id string) {
+// QueueInsertBatch appends all media to the queue in order, starting the first if nothing is playing.
+// Media that still has to be probed starts once it has been, and is dropped if the probe fails.
+// The sender is told which media was queued once every probe has finished, along with the failures
+// they already had, and the channel is sent a single summary.
+func (c *Channel) QueueInsertBatch(sender *Client, items []BatchItem, failed []BatchFailure) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	for i, m := range c.Queued {
-		if m.ID != id {
+	batch := &probeBatch{
+		sender: sender,
+		result: BatchQueued{
+			Failed: failed,
+		},
+	}
+
+	for _, item := range items {
+		m := newEntry(item.Media)
+		m.Probing = Prober != nil
+
+		if !m.Probing && c.Playing == nil {
+			c.play(m)
+			batch.result.Queued++
 			continue
 		}
 
-		c.Queued = slices.Delete(c.Queued, i, i+1)
+		if err := c.checkLimits(m); err != nil {
+			batch.fail(item.Index, m.ID, err)
+			continue
+		}
 
-		c.Emit("media_removed", MediaId{
-			ID: id,
-		})
-		c.SendMessage(ChannelMessage{
-			Type:     MessageTypeMediaRemoved,
-			UTCEpoch: time.Now().Unix(),
-			Username: "System",
-			Content:  fmt.Sprintf("%s - %s has been removed from the queue.", *m.Title, *m.Series),
+		c.Queued = append(c.Queued, m)
+		c.Emit("queue_updated", QueuedMedia{
+			Media:    m,
+			Position: len(c.Queued) - 1,
 		})
+
+		if m.Probing {
+			c.startProbe(m, &pendingProbe{
+				sender: sender,
+				event:  "queue_media_batch",
+				batch:  batch,
+				index:  item.Index,
+			})
+		} else {
+			batch.result.Queued++
+		}
+	}
+
+	c.rebalance()
+	c.reportBatch(batch)
+}
+
+// QueueRemove removes the queue entry, which is allowed for the controller and whoever queued it.
+func (c *Channel) QueueRemove(sender *Client, entryId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := slices.IndexFunc(c.Queued, func(m Media) bool {
+		return m.EntryID == entryId
+	})
+	if i == -1 {
+		sender.EmitError("queue_remove", "The media is not in the queue.")
+		return
 	}
+
+	m := c.Queued[i]
+	if c.controller != sender && submitterOf(m) != sender.User.ID {
+		sender.EmitError("queue_remove", "Only the controller can remove media queued by someone else.")
+		return
+	}
+
+	c.Queued = slices.Delete(c.Queued, i, i+1)
+	c.cancelProbe(m.EntryID)
+
+	c.Emit("media_removed", MediaId{
+		ID:      m.ID,
+		EntryID: m.EntryID,
+	})
+	c.sendMessage(ChannelMessage{
+		Type:     MessageTypeMediaRemoved,
+		UTCEpoch: time.Now().Unix(),
+		Username: "System",
+		Content:  fmt.Sprintf("%s has been removed from the queue.", m.DisplayName()),
+	})
+
+	c.rebalance()
+}
+
+// Records the currently playing media in the history.
+// The lock must be held by the caller.
+func (c *Channel) archive() {
+	if c.Playing == nil {
+		return
+	}
+
+	if len(c.History) >= MaxHistory {
+		c.History = c.History[1:]
+	}
+
+	c.History = append(c.History, HistoryEntry{
+		Media:    c.Playing.Media,
+		PlayedAt: c.Playing.startedAt.Unix(),
+	})
+
+	c.Emit("history_updated", HistoryUpdated{
+		History: c.History,
+	})
+}
+
+// Plays the next media in the queue, picking it at random when shuffling.
+// Media that is still being probed is skipped over.
+// The lock must be held by the caller.
+func (c *Channel) playNext() bool {
+	playable := c.playable()
+	if len(playable) == 0 {
+		return false
+	}
+
+	i := playable[0]
+	if c.Mode.Shuffle {
+		i = playable[rand.IntN(len(playable))]
+	}
+
+	next := c.Queued[i]
+	c.Queued = slices.Delete(c.Queued, i, i+1)
+
+	c.play(next)
+	return true
+}
+
+// Moves on from media that has finished playing according to the playback mode.
+// Returns false when there is nothing left to play.
+// The lock must be held by the caller.
+func (c *Channel) next() bool {
+	if c.Playing == nil {
+		return false
+	}
+
+	c.archive()
+
+	switch c.Mode.Repeat {
+	case RepeatModeOne:
+		c.play(newEntry(c.Playing.Media))
+		return true
+	case RepeatModeQueue:
+		c.requeue(c.Playing.Media, len(c.Queued))
+	}
+
+	return c.playNext()
 }
 
 func (c *Channel) QueueChange() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if len(c.Queued) == 0 {
+	if len(c.playable()) == 0 {
 		return
 	}
 
-	next := c.Queued[0]
-	c.Playing = &NowPlayingMedia{
-		Media:       next,
-		Paused:      false,
-		CurrentTime: 0,
+	if c.Playing != nil && c.Mode.Repeat == RepeatModeQueue {
+		c.requeue(c.Playing.Media, len(c.Queued))
+	}
+
+	c.archive()
+	c.playNext()
+}
 
-		lastChange: time.Now(),
-		ticker:     time.NewTicker(1 * time.Second),
-		finished:   make(chan bool),
+// QueuePrevious replays the last media in the history, moving the current media to the front of the queue.
+func (c *Channel) QueuePrevious() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.History) == 0 {
+		return
 	}
 
-	c.Emit("media_changed", c.Playing)
-	c.SendMessage(ChannelMessage{
-		Type:     MessageTypeMediaChanged,
+	last := c.History[len(c.History)-1]
+	c.History = c.History[:len(c.History)-1]
+
+	c.Emit("history_updated", HistoryUpdated{
+		History: c.History,
+	})
+
+	if c.Playing != nil {
+		c.requeue(c.Playing.Media, 0)
+	}
+
+	c.play(newEntry(last.Media))
+}
+
+// SetPlaybackMode applies the changed fields on top of the channel's current playback mode.
+func (c *Channel) SetPlaybackMode(sender *Client, update PlaybackModeUpdated) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.controller != sender {
+		sender.EmitError("set_playback_mode", "Only the controller can change the playback mode.")
+		return
+	}
+
+	mode := update.apply(c.Mode)
+
+	if mode.Repeat < RepeatModeOff || mode.Repeat > RepeatModeQueue {
+		sender.EmitError("set_playback_mode", "The repeat mode is invalid.")
+		return
+	}
+
+	// Fair mode decides the play order, so it can't be combined with picking at random.
+	if mode.Fair && mode.Shuffle {
+		sender.EmitError("set_playback_mode", "Shuffle can't be used while fair mode is enabled.")
+		return
+	}
+
+	if c.Mode == mode {
+		return
+	}
+
+	c.Mode = mode
+	c.rebalance()
+
+	// Stops waiting on buffering members once the channel no longer waits for everyone.
+	if !c.Mode.WaitForAll && len(c.buffering) != 0 {
+		clear(c.buffering)
+		c.checkBuffering()
+	}
+
+	// Starts the media right away once the channel no longer checks that everyone is ready.
+	if !c.Mode.ReadyCheck && len(c.notReady) != 0 {
+		clear(c.notReady)
+		c.finishReadyCheck()
+	}
+
+	c.Emit("playback_mode_changed", c.Mode)
+	c.sendMessage(ChannelMessage{
+		Type:     MessageTypeNotification,
 		UTCEpoch: time.Now().Unix(),
 		Username: "System",
-		Content:  fmt.Sprintf("%s - %s is now playing.", *next.Title, *next.Series),
+		Content:  fmt.Sprintf("%s has changed the playback mode to %s.", sender.User.Username, c.Mode),
 	})
+}
 
-	c.Queued = c.Queued[1:]
+// QueueShuffle randomises the order of the queued media once.
+func (c *Channel) QueueShuffle(sender *Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.controller != sender {
+		sender.EmitError("run_command", "Only the controller can shuffle the queue.")
+		return
+	}
+
+	if c.Mode.Fair {
+		sender.EmitError("run_command", "The queue can't be shuffled while fair mode is enabled.")
+		return
+	}
+
+	if len(c.Queued) < 2 {
+		return
+	}
+
+	rand.Shuffle(len(c.Queued), func(i, j int) {
+		c.Queued[i], c.Queued[j] = c.Queued[j], c.Queued[i]
+	})
+
+	c.Emit("queue_reordered", QueueReordered{
+		Queue: c.Queued,
+	})
+	c.sendMessage(ChannelMessage{
+		Type:     MessageTypeNotification,
+		UTCEpoch: time.Now().Unix(),
+		Username: "System",
+		Content:  fmt.Sprintf("%s has shuffled the queue.", sender.User.Username),
+	})
 }
 
-func (c *Channel) QueueSort(id string, i int) {
+func (c *Channel) QueueMove(sender *Client, entryId string, i int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if len(c.Queued) == 0 {
+	if c.controller != sender {
+		sender.EmitError("queue_move", "Only the controller can reorder the queue.")
+		return
+	}
+
+	if c.Mode.Fair {
+		sender.EmitError("queue_move", "The queue can't be reordered while fair mode is enabled.")
+		return
+	}
+
+	if i < 0 || i >= len(c.Queued) {
+		sender.EmitError("queue_move", "The position is outside of the queue.")
+		return
+	}
+
+	from := slices.IndexFunc(c.Queued, func(m Media) bool {
+		return m.EntryID == entryId
+	})
+	if from == -1 {
+		sender.EmitError("queue_move", "The media is not in the queue.")
+		return
+	}
+
+	if from == i {
 		return
 	}
 
+	m := c.Queued[from]
+	c.Queued = slices.Insert(slices.Delete(c.Queued, from, from+1), i, m)
+
+	c.Emit("queue_reordered", QueueReordered{
+		Queue: c.Queued,
+	})
+	c.sendMessage(ChannelMessage{
+		Type:     MessageTypeMediaMoved,
+		UTCEpoch: time.Now().Unix(),
+		Username: "System",
+		Content:  fmt.Sprintf("%s has moved %s to position %d in the queue.", sender.User.Username, m.DisplayName(), i+1),
+	})
 }
 
 func (c *Channel) PlayerState(sender *Client, state PlaybackStateUpdated) {
@@ -323,39 +808,56 @@ func (c *Channel) PlayerState(sender *Client, state PlaybackStateUpdated) {
 
 	// Tells the sending client to sync back since they are not the controller.
 	if c.controller != sender {
-		currentPlaybackTime := c.Playing.CurrentPlaybackTime()
+		sender.Emit("state_sync", c.playbackState())
 
-		sender.Emit("state_sync", PlaybackState{
-			Paused:      c.Playing.Paused,
-			CurrentTime: currentPlaybackTime,
-		})
+		return
+	}
+
+	state, err := validateState(c.Playing, state)
+	if err != nil {
+		sender.EmitError("player_state", err.Error())
+		sender.Emit("state_sync", c.playbackState())
+
+		return
+	}
 
+	if state.empty() {
 		return
 	}
 
-	c.Playing.ticker.Stop() // Stop the ticker to prevent sending any updates.
+	// Handles playback rate changes, rebasing the current time so the new rate only applies from now on.
+	if state.PlaybackRate != nil {
+		c.Playing.CurrentTime = c.Playing.CurrentPlaybackTime()
+		c.Playing.lastChange = time.Now()
+		c.Playing.PlaybackRate = *state.PlaybackRate
+	}
+
+	// Handles pause/play state changes, the controller taking over from any wait on other members.
+	if state.Paused != nil {
+		if c.bufferPaused {
+			c.resetBuffering()
+			c.emitWaitingFor()
+		}
 
-	// Handles pause/play state changes.
-	if state.Paused != nil && c.Playing.Paused != *state.Paused {
-		if *state.Paused == false {
-			c.Playing.lastChange = time.Now()
-		} else {
-			c.Playing.CurrentTime = c.Playing.CurrentPlaybackTime()
+		if len(c.notReady) != 0 {
+			c.stopReadyCheck()
+			c.emitReadyCheck()
 		}
 
-		c.Playing.Paused = *state.Paused
+		c.clearScheduledStart()
+
+		c.Playing.setPaused(*state.Paused)
 	}
 
 	// Handles current playback time changes.
-	if state.CurrentTime != nil && c.Playing.CurrentTime != *state.CurrentTime {
+	if state.CurrentTime != nil {
 		c.Playing.lastChange = time.Now()
 		c.Playing.CurrentTime = *state.CurrentTime
 	}
 
-	c.Broadcast("state_updated", PlaybackState{
-		Paused:      c.Playing.Paused,
-		CurrentTime: c.Playing.CurrentTime,
-	}, sender)
+	c.Broadcast("state_updated", c.playbackState(), sender)
+	c.lastSync = time.Now()
 
-	c.Playing.ticker.Reset(1 * time.Second) // Restarts the ticker to start sending updates again
+	// Seeking to the end ticks the channel right away, which moves on from the media.
+	c.reschedule()
 }
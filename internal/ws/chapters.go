@@ -0,0 +1,119 @@
+package ws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The max amount of chapters a single media can have.
+var MaxChapters = 32
+
+type ChapterKind int
+
+const (
+	ChapterKindChapter ChapterKind = iota
+	ChapterKindIntro
+	ChapterKindRecap
+	ChapterKindCredits
+)
+
+type Chapter struct {
+	Title string      `json:"title"`
+	Start float64     `json:"start"`
+	End   float64     `json:"end"`
+	Kind  ChapterKind `json:"kind"`
+}
+
+// ParseChapterKind guesses the kind of chapter from a name such as an HLS date range class.
+func ParseChapterKind(name string) ChapterKind {
+	name = strings.ToLower(name)
+
+	switch {
+	case strings.Contains(name, "intro"), strings.Contains(name, "opening"):
+		return ChapterKindIntro
+	case strings.Contains(name, "recap"):
+		return ChapterKindRecap
+	case strings.Contains(name, "credits"), strings.Contains(name, "ending"), strings.Contains(name, "outro"):
+		return ChapterKindCredits
+	default:
+		return ChapterKindChapter
+	}
+}
+
+// Returns where the playing media is considered finished,
+// which is the start of its credits when the channel skips them.
+// The lock must be held by the caller.
+func (c *Channel) endTime() float64 {
+	end := c.Playing.Duration - 0.5
+
+	if !c.Mode.SkipCredits {
+		return end
+	}
+
+	for _, chapter := range c.Playing.Chapters {
+		if chapter.Kind == ChapterKindCredits && chapter.Start > 0 && chapter.Start < end {
+			end = chapter.Start
+		}
+	}
+
+	return end
+}
+
+// SkipSegment seeks past the intro or recap that is currently playing.
+func (c *Channel) SkipSegment(sender *Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.controller != sender {
+		sender.EmitError("run_command", "Only the controller can skip the intro or recap.")
+		return
+	}
+
+	if c.Playing == nil {
+		return
+	}
+
+	now := c.Playing.CurrentPlaybackTime()
+
+	for _, chapter := range c.Playing.Chapters {
+		if chapter.Kind != ChapterKindIntro && chapter.Kind != ChapterKindRecap {
+			continue
+		}
+
+		if now < chapter.Start || now >= chapter.End {
+			continue
+		}
+
+		c.Playing.CurrentTime = min(chapter.End, c.Playing.Duration)
+		c.Playing.lastChange = time.Now()
+
+		c.Emit("state_updated", c.playbackState())
+		c.lastSync = time.Now()
+
+		c.sendMessage(ChannelMessage{
+			Type:     MessageTypeNotification,
+			UTCEpoch: time.Now().Unix(),
+			Username: "System",
+			Content:  fmt.Sprintf("%s has skipped the %s.", sender.User.Username, chapter.Kind),
+		})
+
+		c.reschedule()
+		return
+	}
+
+	sender.EmitError("run_command", "There is no intro or recap to skip.")
+}
+
+func (k ChapterKind) String() string {
+	switch k {
+	case ChapterKindIntro:
+		return "intro"
+	case ChapterKindRecap:
+		return "recap"
+	case ChapterKindCredits:
+		return "credits"
+	default:
+		return "chapter"
+	}
+}
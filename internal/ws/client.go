@@ -1,6 +1,8 @@
 package ws
 
 import (
+	"strconv"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -11,8 +13,10 @@ import (
 )
 
 var (
-	// The max size a message sent to the Websocket can be.
+	// The size of the Websocket buffers and pending message queues.
 	MaxBufferSize = 512 * 2
+	// The max size a message sent to the Websocket can be.
+	MaxMessageSize = 512 * 32
 	// How long a client has to read the next message.
 	ResponseWait = 60 * time.Second
 	// How long a client has to write a message
@@ -20,10 +24,29 @@ var (
 	// How often the client should be pinged by the server.
 	PingInterval = 30 * time.Second
 
+	// The rate limits applied to events sent by a client, keyed by event name.
+	EventRateLimits = map[string]RateLimit{
+		"queue_media":       {Rate: 0.5, Burst: 3},
+		"queue_media_batch": {Rate: 0.1, Burst: 2},
+		"send_message":      {Rate: 2, Burst: 5},
+		"player_state":      {Rate: 5, Burst: 10},
+		"position_report":   {Rate: 2, Burst: 5},
+		"time_sync":         {Rate: 2, Burst: 10},
+		"buffering_state":   {Rate: 5, Burst: 10},
+		"media_ready":       {Rate: 2, Burst: 5},
+	}
+	// The rate limit applied to events without an entry in EventRateLimits.
+	DefaultEventRateLimit = RateLimit{Rate: 10, Burst: 20}
+	// How many throttled events a client can send within ThrottleWindow before being disconnected.
+	MaxThrottledEvents = 20
+	ThrottleWindow     = 10 * time.Second
+
 	clients = make(map[string]*Client, 0)
 )
 
 type UserInfo struct {
+	// A stable identifier for the user, which persists across connections when restored from a guest token.
+	ID       string
 	Username string
 }
 
@@ -35,10 +58,25 @@ type Client struct {
 	recv chan Message
 
 	handlers map[string][]func(msg any)
+	limits   map[string]*tokenBucket
+
+	throttled      int
+	throttledSince time.Time
+
+	rtt atomic.Int64
+	// Whether the client was disconnected for not keeping up with its messages.
+	dropped atomic.Bool
+	// When the client last reported its playback position, guarded by the channel lock.
+	lastReport time.Time
+	// When the message that is being handled was read.
+	receivedAt time.Time
 
 	User         UserInfo
 	Disconnected chan bool
 	Channel      *Channel
+
+	// Spectators are never waited on when the channel waits for everyone to buffer.
+	Spectator bool
 }
 
 func NewClient(conn *websocket.Conn) *Client {
@@ -52,8 +90,10 @@ func NewClient(conn *websocket.Conn) *Client {
 		recv: make(chan Message, MaxBufferSize),
 
 		handlers: make(map[string][]func(msg any)),
+		limits:   make(map[string]*tokenBucket),
 
 		User: UserInfo{
+			ID:       id,
 			Username: "Guest_" + id,
 		},
 		Disconnected: make(chan bool, 1),
@@ -64,13 +104,39 @@ func NewClient(conn *websocket.Conn) *Client {
 	return client
 }
 
+// Pings the client, sending the current time so the round trip can be measured from the pong.
+func (c *Client) ping() error {
+	c.conn.SetWriteDeadline(time.Now().Add(ReplyWait))
+
+	return c.conn.WriteMessage(websocket.PingMessage, []byte(strconv.FormatInt(time.Now().UnixNano(), 10)))
+}
+
+// RTT returns the round trip time measured from the last pong, or 0 if it hasn't been measured yet.
+func (c *Client) RTT() time.Duration {
+	return time.Duration(c.rtt.Load())
+}
+
 func (c *Client) writePump() {
 	ticker := time.NewTicker(PingInterval)
+
+	// However the pump stops, the client leaves its channel, so a dropped connection never lingers as a member.
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+
+		if c.Channel != nil {
+			c.Channel.leave <- c
+		}
+
+		delete(clients, c.id)
 	}()
 
+	// Measures the round trip time right away instead of waiting for the first ping interval.
+	if err := c.ping(); err != nil {
+		log.WithField("message", err.Error()).WithError(err).Error("Failed to ping client in the desired timespan.")
+		return
+	}
+
 	for {
 		select {
 		case msg, ok := <-c.send:
@@ -94,19 +160,11 @@ func (c *Client) writePump() {
 		case msg := <-c.recv:
 			c.handle(msg)
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(ReplyWait))
-
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := c.ping(); err != nil {
 				log.WithField("message", err.Error()).WithError(err).Error("Failed to ping client in the desired timespan.")
 				return
 			}
 		case <-c.Disconnected:
-			if c.Channel != nil {
-				c.Channel.leave <- c
-			}
-
-			delete(clients, c.id)
-
 			return
 		}
 	}
@@ -117,16 +175,20 @@ func (c *Client) readPump() {
 		close(c.Disconnected)
 	}()
 
-	c.conn.SetReadLimit(int64(MaxBufferSize))
+	c.conn.SetReadLimit(int64(MaxMessageSize))
 	c.conn.SetReadDeadline(time.Now().Add(ResponseWait))
-	c.conn.SetPongHandler(func(_ string) error {
+	c.conn.SetPongHandler(func(data string) error {
+		if sent, err := strconv.ParseInt(data, 10, 64); err == nil {
+			c.rtt.Store(time.Now().UnixNano() - sent)
+		}
+
 		return c.conn.SetReadDeadline(time.Now().Add(ResponseWait))
 	})
 
 	for {
 		msg := new(Message)
 		msgSize := unsafe.Sizeof(*msg)
-		if int(msgSize) > MaxBufferSize {
+		if int(msgSize) > MaxMessageSize {
 			continue
 		}
 
@@ -140,16 +202,73 @@ func (c *Client) readPump() {
 			return
 		}
 
+		msg.receivedAt = time.Now()
 		c.recv <- *msg
 	}
 }
 
+// Returns whether the client is allowed to send the event, disconnecting clients that keep getting throttled.
+func (c *Client) allow(event string) bool {
+	bucket, ok := c.limits[event]
+	if !ok {
+		limit, ok := EventRateLimits[event]
+		if !ok {
+			limit = DefaultEventRateLimit
+		}
+
+		bucket = newTokenBucket(limit)
+		c.limits[event] = bucket
+	}
+
+	if bucket.Allow() {
+		return true
+	}
+
+	if time.Since(c.throttledSince) > ThrottleWindow {
+		c.throttled = 0
+		c.throttledSince = time.Now()
+	}
+	c.throttled++
+
+	if c.throttled > MaxThrottledEvents {
+		log.WithField("client", c.id).Warn("Disconnecting client for exceeding rate limits.")
+
+		c.conn.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "rate limit exceeded"),
+			time.Now().Add(ReplyWait),
+		)
+		c.conn.Close()
+
+		return false
+	}
+
+	c.EmitError(event, "You are doing that too often.")
+	return false
+}
+
 func (c *Client) handle(msg Message) {
+	if _, ok := c.handlers[msg.Event]; !ok {
+		return
+	}
+
+	if !c.allow(msg.Event) {
+		return
+	}
+
+	c.receivedAt = msg.receivedAt
+
 	for _, handler := range c.handlers[msg.Event] {
 		handler(msg.Data)
 	}
 }
 
+// ReceivedAt returns when the message that is being handled was read from the client,
+// before it waited behind the client's other messages to be handled.
+func (c *Client) ReceivedAt() time.Time {
+	return c.receivedAt
+}
+
 func (c *Client) ChannelConnect(channelId string) *Channel {
 	c.Channel = JoinChannel(channelId, c)
 	return c.Channel
@@ -159,9 +278,26 @@ func (c *Client) On(event string, handler func(msg any)) {
 	c.handlers[event] = append(c.handlers[event], handler)
 }
 
+// Emit queues the message for the client without blocking. Clients that fall so far behind
+// that their queue fills up are disconnected, so one slow client can't stall the channel.
 func (c *Client) Emit(event string, msg any) {
-	c.send <- Message{
+	select {
+	case c.send <- Message{
 		Event: event,
 		Data:  msg,
+	}:
+	default:
+		if c.dropped.CompareAndSwap(false, true) {
+			log.WithField("client", c.id).Warn("Disconnecting client that isn't keeping up with its messages.")
+			c.conn.Close()
+		}
 	}
 }
+
+// EmitError tells the client that the event it sent could not be handled.
+func (c *Client) EmitError(event string, message string) {
+	c.Emit("error", Error{
+		Event:   event,
+		Message: message,
+	})
+}
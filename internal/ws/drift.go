@@ -0,0 +1,73 @@
+package ws
+
+import (
+	"math"
+	"time"
+)
+
+var (
+	// How far a client can drift from the server before being told to adjust its playback rate.
+	DriftThreshold = 300 * time.Millisecond
+	// How far a client can drift from the server before being told to seek.
+	SeekThreshold = 2 * time.Second
+	// How long a suggested playback rate should take to correct the drift.
+	DriftCorrectionWindow = 10 * time.Second
+	// The furthest a suggested playback rate can stray from real time.
+	MaxRateCorrection = 0.1
+	// How often clients are expected to report their position, clients that haven't reported
+	// within twice this interval are kept in sync with periodic state_sync events instead.
+	PositionReportInterval = 5 * time.Second
+)
+
+// Returns whether the client is reporting its position, meaning it gets targeted corrections.
+// The lock must be held by the caller.
+func (c *Client) reportsPosition() bool {
+	return time.Since(c.lastReport) < 2*PositionReportInterval
+}
+
+// ReportPosition compares the client's playback position against the server, sending a correction if it has drifted.
+func (c *Channel) ReportPosition(sender *Client, currentTime float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Playing == nil || math.IsNaN(currentTime) || math.IsInf(currentTime, 0) {
+		return
+	}
+
+	sender.lastReport = time.Now()
+
+	state := c.playbackState()
+	expected := state.CurrentTime
+
+	// The report spent roughly half the round trip in transit, so the client has moved on since sending it.
+	reported := currentTime
+	if !c.Playing.Paused {
+		reported += (sender.RTT() / 2).Seconds() * state.PlaybackRate
+	}
+
+	drift := reported - expected
+	switch {
+	case math.Abs(drift) >= SeekThreshold.Seconds():
+		sender.Emit("state_sync", state)
+	case math.Abs(drift) >= DriftThreshold.Seconds() && !c.Playing.Paused:
+		correction := max(-MaxRateCorrection, min(MaxRateCorrection, drift/DriftCorrectionWindow.Seconds()))
+
+		sender.Emit("rate_correction", RateCorrection{
+			PlaybackRate: state.PlaybackRate * (1 - correction),
+			Drift:        drift,
+			Duration:     DriftCorrectionWindow.Seconds(),
+		})
+	}
+}
+
+// Sends the playback state to every client that isn't reporting its own position.
+// The lock must be held by the caller.
+func (c *Channel) syncUnreported(state PlaybackState) {
+	for client := range c.connections {
+		if client.reportsPosition() {
+			continue
+		}
+
+		client.Emit("state_sync", state)
+	}
+}
@@ -0,0 +1,68 @@
+package ws
+
+import "slices"
+
+// Returns who queued the media, grouping media without a submitter together.
+func submitterOf(m Media) string {
+	if m.QueuedBy == nil {
+		return ""
+	}
+
+	return m.QueuedBy.ID
+}
+
+// Orders the queue round-robin by submitter, keeping each submitter's own order.
+// Submitters take turns in the order they first appear in the queue.
+func fairOrder(queue []Media) []Media {
+	order := make([]string, 0)
+	bySubmitter := make(map[string][]Media)
+
+	for _, m := range queue {
+		id := submitterOf(m)
+		if _, ok := bySubmitter[id]; !ok {
+			order = append(order, id)
+		}
+
+		bySubmitter[id] = append(bySubmitter[id], m)
+	}
+
+	fair := make([]Media, 0, len(queue))
+	for round := 0; len(fair) < len(queue); round++ {
+		for _, id := range order {
+			if round < len(bySubmitter[id]) {
+				fair = append(fair, bySubmitter[id][round])
+			}
+		}
+	}
+
+	return fair
+}
+
+// Reorders the queue fairly when fair mode is enabled, broadcasting the new order if it changed.
+// The lock must be held by the caller.
+func (c *Channel) rebalance() {
+	if !c.Mode.Fair {
+		return
+	}
+
+	fair := fairOrder(c.Queued)
+	if slices.EqualFunc(fair, c.Queued, func(a, b Media) bool { return a.EntryID == b.EntryID }) {
+		return
+	}
+
+	c.Queued = fair
+	c.Emit("queue_reordered", QueueReordered{
+		Queue: c.Queued,
+	})
+}
+
+// Appends the media to the queue at its fair position, returning where it landed.
+// The queue is expected to already be in fair order.
+// The lock must be held by the caller.
+func (c *Channel) insertFair(m Media) int {
+	c.Queued = fairOrder(append(c.Queued, m))
+
+	return slices.IndexFunc(c.Queued, func(queued Media) bool {
+		return queued.EntryID == m.EntryID
+	})
+}
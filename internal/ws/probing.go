@@ -0,0 +1,261 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MediaProber fills in the duration and stream info of queued media.
+type MediaProber interface {
+	ProbeMedia(ctx context.Context, m *Media) error
+}
+
+var (
+	// The prober used for queued media, nil queues media as it was sent.
+	Prober MediaProber
+	// How many media can be probed at once across all channels.
+	ProbeConcurrency = 4
+
+	probeSlots     chan struct{}
+	probeSlotsOnce sync.Once
+)
+
+// A queue entry whose probe is still running.
+type pendingProbe struct {
+	cancel context.CancelFunc
+	// Who queued the media and the event they queued it with, to tell them when the probe fails.
+	sender *Client
+	event  string
+	// Whether the media starts playing as soon as it is probed.
+	playNow bool
+	// The batch the media was queued in and its index in that batch, nil when it was queued on its own.
+	batch *probeBatch
+	index int
+}
+
+// A batch of media that is reported on once every one of its probes has finished.
+type probeBatch struct {
+	sender  *Client
+	pending int
+	result  BatchQueued
+}
+
+// Records that the media at the index of the batch could not be queued.
+func (b *probeBatch) fail(index int, id string, err error) {
+	b.result.Failed = append(b.result.Failed, BatchFailure{
+		Index:  index,
+		ID:     id,
+		Reason: err.Error(),
+	})
+}
+
+// Starts probing the queue entry in the background, it is updated or dropped once the probe finishes.
+// The lock must be held by the caller.
+func (c *Channel) startProbe(m Media, p *pendingProbe) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p.cancel = cancel
+	c.probes[m.EntryID] = p
+
+	if p.batch != nil {
+		p.batch.pending++
+	}
+
+	go func() {
+		defer cancel()
+
+		probeSlotsOnce.Do(func() {
+			probeSlots = make(chan struct{}, max(1, ProbeConcurrency))
+		})
+
+		select {
+		case probeSlots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		err := Prober.ProbeMedia(ctx, &m)
+		<-probeSlots
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.finishProbe(m, err)
+	}()
+}
+
+// Stops the probe of the queue entry, if one is running.
+// The lock must be held by the caller.
+func (c *Channel) cancelProbe(entryId string) {
+	if p, ok := c.probes[entryId]; ok {
+		p.cancel()
+		delete(c.probes, entryId)
+
+		// The media made it into the queue, so it counts as queued even though it was removed since.
+		c.settleProbe(p, true)
+	}
+}
+
+// Stops every running probe.
+// The lock must be held by the caller.
+func (c *Channel) cancelProbes() {
+	for entryId := range c.probes {
+		c.cancelProbe(entryId)
+	}
+}
+
+// Replaces the queue entry with the probed media, or drops it when the probe failed.
+// The lock must be held by the caller.
+func (c *Channel) finishProbe(m Media, err error) {
+	p, ok := c.probes[m.EntryID]
+	if !ok {
+		// The entry was removed while it was being probed.
+		return
+	}
+	delete(c.probes, m.EntryID)
+
+	i := slices.IndexFunc(c.Queued, func(queued Media) bool {
+		return queued.EntryID == m.EntryID
+	})
+	if i == -1 {
+		c.settleProbe(p, true)
+		return
+	}
+
+	if err == nil {
+		err = c.checkDuration(m)
+	}
+
+	if err != nil {
+		log.WithError(err).WithField("url", m.URL).Debug("Failed to probe queued media.")
+
+		c.Queued = slices.Delete(c.Queued, i, i+1)
+		c.rebalance()
+
+		c.Emit("media_removed", MediaId{
+			ID:      m.ID,
+			EntryID: m.EntryID,
+		})
+
+		// Media queued in a batch is reported on with the rest of its batch.
+		if p.batch != nil {
+			p.batch.fail(p.index, m.ID, err)
+			c.settleProbe(p, false)
+		} else {
+			c.sendMessage(ChannelMessage{
+				Type:     MessageTypeMediaRemoved,
+				UTCEpoch: time.Now().Unix(),
+				Username: "System",
+				Content:  fmt.Sprintf("%s could not be loaded and has been removed from the queue.", m.DisplayName()),
+			})
+
+			// The member may have left while their media was being probed.
+			if _, ok := c.connections[p.sender]; ok {
+				p.sender.EmitError(p.event, fmt.Sprintf("%s could not be loaded: %s", m.DisplayName(), err))
+			}
+		}
+
+		c.startIfIdle()
+		return
+	}
+
+	m.Probing = false
+
+	if p.playNow {
+		c.Queued = slices.Delete(c.Queued, i, i+1)
+		c.Emit("media_removed", MediaId{
+			ID:      m.ID,
+			EntryID: m.EntryID,
+		})
+
+		if c.Playing != nil {
+			c.requeue(c.Playing.Media, 0)
+		}
+
+		c.play(m)
+		return
+	}
+
+	c.Queued[i] = m
+	c.Emit("media_updated", QueuedMedia{
+		Media:    m,
+		Position: i,
+	})
+
+	c.settleProbe(p, true)
+	c.startIfIdle()
+}
+
+// Counts a finished probe towards its batch, reporting on the batch once none of its probes are left.
+// The lock must be held by the caller.
+func (c *Channel) settleProbe(p *pendingProbe, queued bool) {
+	if p.batch == nil {
+		return
+	}
+
+	if queued {
+		p.batch.result.Queued++
+	}
+
+	p.batch.pending--
+	c.reportBatch(p.batch)
+}
+
+// Tells the sender which media of the batch was queued and posts a summary, once none of it is being probed.
+// The lock must be held by the caller.
+func (c *Channel) reportBatch(b *probeBatch) {
+	if b.pending != 0 {
+		return
+	}
+
+	slices.SortFunc(b.result.Failed, func(x, y BatchFailure) int {
+		return x.Index - y.Index
+	})
+
+	// The member may have left while their media was being probed.
+	if _, ok := c.connections[b.sender]; ok {
+		b.sender.Emit("media_batch_queued", b.result)
+	}
+
+	if b.result.Queued != 0 {
+		c.sendMessage(ChannelMessage{
+			Type:     MessageTypeMediaQueued,
+			UTCEpoch: time.Now().Unix(),
+			Username: "System",
+			Content:  fmt.Sprintf("%s has added %d items to the queue.", b.sender.User.Username, b.result.Queued),
+		})
+	}
+}
+
+// Starts the queue once its front has been probed, if nothing is playing.
+// Waiting on the front keeps media starting in the order it was queued.
+// The lock must be held by the caller.
+func (c *Channel) startIfIdle() {
+	if c.Playing != nil || len(c.Queued) == 0 {
+		return
+	}
+
+	if !c.Mode.Shuffle && c.Queued[0].Probing {
+		return
+	}
+
+	c.playNext()
+}
+
+// Returns the indexes of the queued media that can be played, leaving out media still being probed.
+// The lock must be held by the caller.
+func (c *Channel) playable() []int {
+	indexes := make([]int, 0, len(c.Queued))
+	for i, m := range c.Queued {
+		if !m.Probing {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return indexes
+}
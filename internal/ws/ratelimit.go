@@ -0,0 +1,41 @@
+package ws
+
+import (
+	"time"
+)
+
+type RateLimit struct {
+	// How many events are refilled per second.
+	Rate float64
+	// The max amount of events that can be sent at once.
+	Burst float64
+}
+
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	return &tokenBucket{
+		limit:  limit,
+		tokens: limit.Burst,
+		last:   time.Now(),
+	}
+}
+
+// Allow consumes a token from the bucket, returning false if none are available.
+func (b *tokenBucket) Allow() bool {
+	now := time.Now()
+
+	b.tokens = min(b.limit.Burst, b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
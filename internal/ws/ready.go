@@ -0,0 +1,115 @@
+package ws
+
+import (
+	"time"
+)
+
+// How long a ready check waits on members before starting the media without them.
+var ReadyCheckTimeout = 10 * time.Second
+
+// Holds newly started media paused at zero until every member has loaded it.
+// The lock must be held by the caller.
+func (c *Channel) startReadyCheck() {
+	c.stopReadyCheck()
+
+	for client := range c.connections {
+		if !client.Spectator {
+			c.notReady[client] = true
+		}
+	}
+
+	if len(c.notReady) == 0 {
+		return
+	}
+
+	c.Playing.Paused = true
+
+	playing := c.Playing
+	c.readyTimer = time.AfterFunc(ReadyCheckTimeout, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.Playing == playing && len(c.notReady) != 0 {
+			clear(c.notReady)
+			c.finishReadyCheck()
+		}
+	})
+
+	c.emitReadyCheck()
+}
+
+// Cancels any ready check in progress without starting the media.
+// The lock must be held by the caller.
+func (c *Channel) stopReadyCheck() {
+	clear(c.notReady)
+
+	if c.readyTimer != nil {
+		c.readyTimer.Stop()
+		c.readyTimer = nil
+	}
+}
+
+// Starts the media once the ready check has no one left to wait on.
+// The lock must be held by the caller.
+func (c *Channel) finishReadyCheck() {
+	c.emitReadyCheck()
+
+	if len(c.notReady) != 0 {
+		return
+	}
+
+	c.stopReadyCheck()
+
+	// Media scheduled to start is held paused until its start time instead.
+	if !c.scheduledStart.IsZero() {
+		return
+	}
+
+	c.Playing.setPaused(false)
+
+	c.Emit("state_updated", c.playbackState())
+	c.lastSync = time.Now()
+	c.reschedule()
+}
+
+// The lock must be held by the caller.
+func (c *Channel) emitReadyCheck() {
+	members := make([]Submitter, 0, len(c.notReady))
+	for client := range c.notReady {
+		members = append(members, Submitter{
+			ID:       client.User.ID,
+			Username: client.User.Username,
+		})
+	}
+
+	c.Emit("ready_check", ReadyCheck{
+		EntryID: c.Playing.EntryID,
+		Waiting: members,
+	})
+}
+
+// MediaReady marks the client's player as having loaded the media with the given entry ID.
+func (c *Channel) MediaReady(sender *Client, entryId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Playing == nil || c.Playing.EntryID != entryId || !c.notReady[sender] {
+		return
+	}
+
+	delete(c.notReady, sender)
+	c.finishReadyCheck()
+}
+
+// Stops waiting on a client in the ready check, such as when it leaves the channel.
+func (c *Channel) skipReadyCheckFor(client *Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.notReady[client] {
+		return
+	}
+
+	delete(c.notReady, client)
+	c.finishReadyCheck()
+}
@@ -0,0 +1,161 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// How far ahead media can be scheduled to start.
+	MaxScheduleAhead = 7 * 24 * time.Hour
+	// How long before a scheduled start the countdown is sent every second.
+	FinalCountdown = 10 * time.Second
+
+	ErrStartInPast    = errors.New("the start time has already passed")
+	ErrStartTooFar    = errors.New("the start time is too far ahead")
+	ErrNothingToStart = errors.New("there is no media to start")
+	ErrNotScheduled   = errors.New("nothing is scheduled to start")
+	ErrNotController  = errors.New("only the controller can schedule when media starts")
+)
+
+// ScheduleStart holds the current media paused until the given time, then starts it for everyone.
+// When next is set, the next media in the queue is started and held instead.
+// Scheduling again moves the start time.
+func (c *Channel) ScheduleStart(sender *Client, startAt time.Time, next bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.controller != sender {
+		return ErrNotController
+	}
+
+	if !startAt.After(time.Now()) {
+		return ErrStartInPast
+	}
+
+	if time.Until(startAt) > MaxScheduleAhead {
+		return ErrStartTooFar
+	}
+
+	if next {
+		// Repeating puts the current media back up next, so there is something to start even with an empty queue.
+		if len(c.playable()) == 0 && (c.Playing == nil || c.Mode.Repeat == RepeatModeOff) {
+			return ErrNothingToStart
+		}
+
+		if !c.next() {
+			c.playNext()
+		}
+	}
+
+	if c.Playing == nil {
+		return ErrNothingToStart
+	}
+
+	// The scheduled start takes over from waiting on buffering members, so they can't resume it early.
+	if c.bufferPaused {
+		c.resetBuffering()
+		c.emitWaitingFor()
+	}
+
+	c.scheduledStart = startAt
+	c.Playing.setPaused(true)
+
+	c.Emit("start_scheduled", StartScheduled{
+		EntryID: c.Playing.EntryID,
+		StartAt: startAt.UnixMilli(),
+		State:   c.roomState(),
+	})
+	c.Emit("state_updated", c.playbackState())
+	c.sendMessage(ChannelMessage{
+		Type:     MessageTypeNotification,
+		UTCEpoch: time.Now().Unix(),
+		Username: "System",
+		Content:  fmt.Sprintf("%s has scheduled %s to start at %s.", sender.User.Username, c.Playing.DisplayName(), startAt.UTC().Format(time.RFC1123)),
+	})
+
+	c.reschedule()
+	return nil
+}
+
+// CancelScheduledStart cancels the scheduled start, leaving the media paused.
+func (c *Channel) CancelScheduledStart(sender *Client) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.controller != sender {
+		return ErrNotController
+	}
+
+	if c.scheduledStart.IsZero() {
+		return ErrNotScheduled
+	}
+
+	c.clearScheduledStart()
+	c.sendMessage(ChannelMessage{
+		Type:     MessageTypeNotification,
+		UTCEpoch: time.Now().Unix(),
+		Username: "System",
+		Content:  fmt.Sprintf("%s has cancelled the scheduled start.", sender.User.Username),
+	})
+
+	c.reschedule()
+	return nil
+}
+
+// Forgets the scheduled start, telling clients if there was one.
+// The lock must be held by the caller.
+func (c *Channel) clearScheduledStart() {
+	if c.scheduledStart.IsZero() {
+		return
+	}
+
+	c.scheduledStart = time.Time{}
+	c.Emit("start_cancelled", StartScheduled{
+		State: c.roomState(),
+	})
+}
+
+// Returns when the countdown to the scheduled start should next be sent.
+func (c *Channel) nextCountdown(now time.Time) time.Time {
+	remaining := c.scheduledStart.Sub(now)
+
+	switch {
+	case remaining <= 0:
+		return c.scheduledStart
+	case remaining > FinalCountdown:
+		return now.Add(min(SyncInterval, remaining-FinalCountdown))
+	default:
+		// Lines the countdown up with the whole seconds left until the start.
+		return c.scheduledStart.Add(-remaining.Truncate(time.Second))
+	}
+}
+
+// Sends the countdown, starting the media once the scheduled time is reached.
+// The lock must be held by the caller.
+func (c *Channel) countdown() {
+	now := time.Now()
+
+	if now.Before(c.scheduledStart) {
+		c.Emit("countdown", Countdown{
+			EntryID:   c.Playing.EntryID,
+			StartAt:   c.scheduledStart.UnixMilli(),
+			Remaining: c.scheduledStart.Sub(now).Seconds(),
+		})
+
+		c.reschedule()
+		return
+	}
+
+	c.scheduledStart = time.Time{}
+
+	// Members still loading the media are not waited on any longer.
+	c.stopReadyCheck()
+	c.Playing.setPaused(false)
+
+	c.Emit("state_updated", c.playbackState())
+	c.lastSync = now
+
+	c.reschedule()
+}
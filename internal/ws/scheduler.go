@@ -0,0 +1,250 @@
+package ws
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// How often the playback state is sent to clients that aren't reporting their own position.
+var SyncInterval = 10 * time.Second
+
+type deadline struct {
+	at      time.Time
+	channel *Channel
+	index   int
+}
+
+type deadlineHeap []*deadline
+
+func (h deadlineHeap) Len() int           { return len(h) }
+func (h deadlineHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+
+func (h deadlineHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *deadlineHeap) Push(x any) {
+	d := x.(*deadline)
+	d.index = len(*h)
+	*h = append(*h, d)
+}
+
+func (h *deadlineHeap) Pop() any {
+	old := *h
+	d := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+
+	return d
+}
+
+// The scheduler keeps a single deadline per channel for every channel that is playing,
+// firing them all from one goroutine instead of running a ticker per channel.
+type scheduler struct {
+	mu sync.Mutex
+
+	deadlines deadlineHeap
+	channels  map[*Channel]*deadline
+
+	wake chan struct{}
+}
+
+var playbackScheduler = &scheduler{
+	deadlines: make(deadlineHeap, 0),
+	channels:  make(map[*Channel]*deadline),
+
+	wake: make(chan struct{}, 1),
+}
+
+func init() {
+	go playbackScheduler.run()
+}
+
+// Schedule sets when the channel should next be ticked, replacing its previous deadline.
+func (s *scheduler) Schedule(c *Channel, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d, ok := s.channels[c]; ok {
+		d.at = at
+		heap.Fix(&s.deadlines, d.index)
+	} else {
+		d := &deadline{
+			at:      at,
+			channel: c,
+		}
+
+		heap.Push(&s.deadlines, d)
+		s.channels[c] = d
+	}
+
+	if s.deadlines[0].channel == c {
+		s.notify()
+	}
+}
+
+// Cancel removes the channel's deadline, if it has one.
+func (s *scheduler) Cancel(c *Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.channels[c]
+	if !ok {
+		return
+	}
+
+	heap.Remove(&s.deadlines, d.index)
+	delete(s.channels, c)
+}
+
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+
+		now := time.Now()
+		due := make([]*deadline, 0)
+		for len(s.deadlines) != 0 && !s.deadlines[0].at.After(now) {
+			d := heap.Pop(&s.deadlines).(*deadline)
+			delete(s.channels, d.channel)
+
+			due = append(due, d)
+		}
+
+		wait := time.Hour
+		if len(s.deadlines) != 0 {
+			wait = s.deadlines[0].at.Sub(now)
+		}
+
+		s.mu.Unlock()
+
+		// Channels are ticked without holding the scheduler lock, since ticking reschedules them.
+		for _, d := range due {
+			d.channel.tick(d.at)
+		}
+
+		if len(due) != 0 {
+			continue
+		}
+
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// Schedules the channel's next tick, either for when the playing media ends or the next sync, whichever comes first.
+// The lock must be held by the caller.
+func (c *Channel) reschedule() {
+	if c.Playing == nil {
+		c.deadline = time.Time{}
+		playbackScheduler.Cancel(c)
+		return
+	}
+
+	now := time.Now()
+
+	if !c.scheduledStart.IsZero() {
+		c.deadline = c.nextCountdown(now)
+		playbackScheduler.Schedule(c, c.deadline)
+		return
+	}
+
+	remaining := c.endTime() - c.Playing.PlaybackTimeAt(now)
+
+	var at time.Time
+	switch {
+	case remaining <= 0:
+		at = now
+	case c.Playing.Paused:
+		c.deadline = time.Time{}
+		playbackScheduler.Cancel(c)
+		return
+	default:
+		at = now.Add(time.Duration(remaining / c.Playing.PlaybackRate * float64(time.Second)))
+
+		if sync := c.lastSync.Add(SyncInterval); sync.Before(at) {
+			at = sync
+		}
+	}
+
+	c.deadline = at
+	playbackScheduler.Schedule(c, at)
+}
+
+// Handles a deadline firing, moving on from media that has ended and keeping clients in sync.
+func (c *Channel) tick(at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The channel was rescheduled after this deadline was taken off the heap,
+	// or the next episode is already being looked up.
+	if !at.Equal(c.deadline) || c.Playing == nil || c.loadingNext {
+		return
+	}
+
+	c.deadline = time.Time{}
+
+	if !c.scheduledStart.IsZero() {
+		c.countdown()
+		return
+	}
+
+	if c.Playing.CurrentPlaybackTime() >= c.endTime() {
+		if c.next() {
+			return
+		}
+
+		// Looking up the next episode may block, so it runs on its own and finishes the media if nothing is found.
+		if NextEpisodes != nil && c.Mode.Autoplay {
+			c.loadingNext = true
+			c.Emit("state_updated", c.playbackState())
+
+			go c.autoplay(c.Playing)
+			return
+		}
+
+		c.finish()
+		return
+	}
+
+	if !c.Playing.Paused {
+		c.syncUnreported(c.playbackState())
+		c.lastSync = time.Now()
+	}
+
+	c.reschedule()
+}
+
+// Stops playback once media has ended with nothing left to play.
+// The lock must be held by the caller.
+func (c *Channel) finish() {
+	c.resetBuffering()
+	c.stopReadyCheck()
+
+	ended := c.Playing
+	c.Playing = nil
+	c.ended = true
+	c.loadingNext = false
+
+	c.Emit("media_ended", MediaEnded{
+		EntryID: ended.EntryID,
+		State:   c.roomState(),
+	})
+
+	c.reschedule()
+}
@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"slices"
+)
+
+type RoomState int
+
+const (
+	// Nothing has been played yet.
+	RoomStateIdle RoomState = iota
+	// Media is waiting on members to load it, or the next episode is being looked up.
+	RoomStateLoading
+	RoomStatePlaying
+	RoomStatePaused
+	// The last media finished with nothing left to play.
+	RoomStateEnded
+	// Media is held paused until its scheduled start time.
+	RoomStateScheduled
+)
+
+// The lock must be held by the caller.
+func (c *Channel) roomState() RoomState {
+	switch {
+	case c.Playing == nil && c.ended:
+		return RoomStateEnded
+	case c.Playing == nil:
+		return RoomStateIdle
+	case len(c.notReady) != 0 || c.loadingNext:
+		return RoomStateLoading
+	case !c.scheduledStart.IsZero():
+		return RoomStateScheduled
+	case c.Playing.Paused:
+		return RoomStatePaused
+	default:
+		return RoomStatePlaying
+	}
+}
+
+// Returns the playback state of the playing media along with the room state.
+// The lock must be held by the caller.
+func (c *Channel) playbackState() PlaybackState {
+	state := c.Playing.State()
+	state.RoomState = c.roomState()
+
+	return state
+}
+
+// Returns a copy of the playing media that is safe to send to clients, or nil if nothing is playing.
+// The lock must be held by the caller.
+func (c *Channel) nowPlaying() *NowPlayingMedia {
+	if c.Playing == nil {
+		return nil
+	}
+
+	nowPlaying := c.Playing.Snapshot()
+	nowPlaying.RoomState = c.roomState()
+
+	return nowPlaying
+}
+
+// RoomData returns everything a client needs to catch up with the channel when joining.
+func (c *Channel) RoomData() RoomData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var scheduledStart int64
+	if !c.scheduledStart.IsZero() {
+		scheduledStart = c.scheduledStart.UnixMilli()
+	}
+
+	return RoomData{
+		NowPlaying: c.nowPlaying(),
+		Queue:      slices.Clone(c.Queued),
+		Messages:   slices.Clone(c.Messages),
+		Mode:       c.Mode,
+		History:    slices.Clone(c.History),
+		State:      c.roomState(),
+
+		ScheduledStart: scheduledStart,
+	}
+}
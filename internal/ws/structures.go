@@ -1,12 +1,18 @@
 package ws
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // -- Clients --
 
 type Message struct {
 	Event string `json:"event"`
 	Data  any    `json:"data"`
+
+	// When the message was read from the client, zero for messages sent by the server.
+	receivedAt time.Time
 }
 
 type ClientJoinedRoom struct {
@@ -22,15 +28,169 @@ type ClientMessage struct {
 	Message  string `json:"message"`
 }
 
+type Error struct {
+	Event   string `json:"event"`
+	Message string `json:"message"`
+}
+
+type GuestToken struct {
+	Token string `json:"token"`
+}
+
 type PlaybackState struct {
-	Paused      bool    `json:"paused"`
-	CurrentTime float64 `json:"current_time"`
+	Paused       bool    `json:"paused"`
+	CurrentTime  float64 `json:"current_time"`
+	PlaybackRate float64 `json:"playback_rate"`
+	// The server time in unix milliseconds that the current time refers to.
+	ServerTime int64     `json:"server_time"`
+	RoomState  RoomState `json:"state"`
+}
+
+type TimeSync struct {
+	// The client time the request was sent at, echoed back as is.
+	ClientTime float64 `json:"client_time"`
+	// The server times in unix milliseconds the request was received and the response was sent.
+	ServerReceiveTime int64 `json:"server_receive_time"`
+	ServerSendTime    int64 `json:"server_send_time"`
+}
+
+type WaitingFor struct {
+	Members []Submitter `json:"members"`
+}
+
+type ReadyCheck struct {
+	EntryID string      `json:"entry_id"`
+	Waiting []Submitter `json:"waiting"`
+}
+
+type RateCorrection struct {
+	// The playback rate the client should use to catch up or fall back.
+	PlaybackRate float64 `json:"playback_rate"`
+	// How far ahead of the server the client is, in seconds.
+	Drift float64 `json:"drift"`
+	// How long the client should keep the playback rate for, in seconds.
+	Duration float64 `json:"duration"`
 }
 
 type RoomData struct {
 	NowPlaying *NowPlayingMedia `json:"now_playing"`
 	Queue      []Media          `json:"queue"`
 	Messages   []ChannelMessage `json:"messages"`
+	Mode       PlaybackMode     `json:"mode"`
+	History    []HistoryEntry   `json:"history"`
+	State      RoomState        `json:"state"`
+
+	// The server time in unix milliseconds the playing media is scheduled to start at, 0 if it isn't.
+	ScheduledStart int64 `json:"scheduled_start"`
+}
+
+type RepeatMode int
+
+const (
+	RepeatModeOff RepeatMode = iota
+	// Replays the current media once it finishes.
+	RepeatModeOne
+	// Moves finished media to the end of the queue.
+	RepeatModeQueue
+)
+
+type PlaybackMode struct {
+	Repeat RepeatMode `json:"repeat"`
+	// Picks the next media at random from the queue.
+	Shuffle bool `json:"shuffle"`
+	// Orders the queue round-robin by who queued each media.
+	Fair bool `json:"fair"`
+	// Plays the next episode of the series once the queue runs dry.
+	Autoplay bool `json:"autoplay"`
+	// Pauses the room while any member is buffering.
+	WaitForAll bool `json:"wait_for_all"`
+	// Holds new media paused at the start until every member has loaded it.
+	ReadyCheck bool `json:"ready_check"`
+	// Moves on to the next media once the credits start.
+	SkipCredits bool `json:"skip_credits"`
+}
+
+func (m PlaybackMode) String() string {
+	var mode string
+
+	switch m.Repeat {
+	case RepeatModeOne:
+		mode = "repeat one"
+	case RepeatModeQueue:
+		mode = "loop queue"
+	default:
+		mode = "normal"
+	}
+
+	if m.Shuffle {
+		mode += " (shuffled)"
+	}
+
+	if m.Fair {
+		mode += " (fair)"
+	}
+
+	if m.Autoplay {
+		mode += " (autoplay)"
+	}
+
+	if m.WaitForAll {
+		mode += " (wait for everyone)"
+	}
+
+	if m.ReadyCheck {
+		mode += " (ready check)"
+	}
+
+	if m.SkipCredits {
+		mode += " (skip credits)"
+	}
+
+	return mode
+}
+
+// The playback mode fields a client changed, nil fields are left as they are.
+type PlaybackModeUpdated struct {
+	Repeat      *RepeatMode
+	Shuffle     *bool
+	Fair        *bool
+	Autoplay    *bool
+	WaitForAll  *bool
+	ReadyCheck  *bool
+	SkipCredits *bool
+}
+
+// Returns the mode with the changed fields applied.
+func (u PlaybackModeUpdated) apply(mode PlaybackMode) PlaybackMode {
+	if u.Repeat != nil {
+		mode.Repeat = *u.Repeat
+	}
+
+	if u.Shuffle != nil {
+		mode.Shuffle = *u.Shuffle
+	}
+
+	if u.Fair != nil {
+		mode.Fair = *u.Fair
+	}
+
+	if u.Autoplay != nil {
+		mode.Autoplay = *u.Autoplay
+	}
+
+	if u.WaitForAll != nil {
+		mode.WaitForAll = *u.WaitForAll
+	}
+
+	if u.ReadyCheck != nil {
+		mode.ReadyCheck = *u.ReadyCheck
+	}
+
+	if u.SkipCredits != nil {
+		mode.SkipCredits = *u.SkipCredits
+	}
+
+	return mode
 }
 
 // -- Channels --
@@ -41,40 +201,185 @@ type BroadcastMessage struct {
 }
 
 type MediaId struct {
-	ID string `json:"id"`
+	ID      string `json:"id"`
+	EntryID string `json:"entry_id"`
+}
+
+type Submitter struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
 }
 
 type Media struct {
-	ID             string  `json:"id"`
-	Title          *string `json:"title"`
-	Series         *string `json:"series"`
-	Episode        *int    `json:"episode"`
-	URL            string  `json:"url"`
-	PosterImageURL *string `json:"poster_image_url"`
-	Duration       float64 `json:"-"`
+	// Identifies this media in the queue, generated by the server when it is queued.
+	EntryID        string     `json:"entry_id"`
+	ID             string     `json:"id"`
+	Title          *string    `json:"title"`
+	Series         *string    `json:"series"`
+	Episode        *int       `json:"episode"`
+	URL            string     `json:"url"`
+	PosterImageURL *string    `json:"poster_image_url"`
+	QueuedBy       *Submitter `json:"queued_by"`
+	QueuedAt       int64      `json:"queued_at"`
+	Chapters       []Chapter  `json:"chapters"`
+	Duration       float64    `json:"-"`
+
+	// The resolution and codecs found when probing the media, left empty when unknown.
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	Codecs []string `json:"codecs"`
+	// Whether the media is still being probed, it can't be played until it has been.
+	Probing bool `json:"probing"`
+}
+
+// DisplayName formats the media for system messages, falling back to the ID when it has no title.
+func (m Media) DisplayName() string {
+	title := m.ID
+	if m.Title != nil {
+		title = *m.Title
+	}
+
+	if m.Series == nil {
+		return title
+	}
+
+	return fmt.Sprintf("%s - %s", title, *m.Series)
+}
+
+// The position used to append media to the end of the queue.
+const QueuePositionEnd = -1
+
+type QueueOptions struct {
+	// The index the media is inserted at, or QueuePositionEnd to append it.
+	Position int
+	// Starts the media immediately, moving the currently playing media to the front of the queue.
+	PlayNow bool
+}
+
+type QueuedMedia struct {
+	Media
+	Position int `json:"position"`
+}
+
+// BatchItem is media from a queue_media_batch, along with its index in the batch that was sent.
+type BatchItem struct {
+	Index int
+	Media Media
+}
+
+type BatchFailure struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+type BatchQueued struct {
+	Queued int            `json:"queued"`
+	Failed []BatchFailure `json:"failed"`
+}
+
+type QueueReordered struct {
+	Queue []Media `json:"queue"`
 }
 
 type NowPlayingMedia struct {
 	Media
-	Paused      bool    `json:"paused"`
-	CurrentTime float64 `json:"current_time"`
+	Paused       bool    `json:"paused"`
+	CurrentTime  float64 `json:"current_time"`
+	PlaybackRate float64 `json:"playback_rate"`
+	// The server time in unix milliseconds that the current time refers to.
+	ServerTime int64     `json:"server_time"`
+	RoomState  RoomState `json:"state"`
 
+	startedAt  time.Time
 	lastChange time.Time
-	ticker     *time.Ticker
-	finished   chan bool
 }
 
-func (n *NowPlayingMedia) CurrentPlaybackTime() float64 {
+// Pauses or resumes playback, keeping the current time in place.
+func (n *NowPlayingMedia) setPaused(paused bool) {
+	if n.Paused == paused {
+		return
+	}
+
+	if paused {
+		n.CurrentTime = n.CurrentPlaybackTime()
+	} else {
+		n.lastChange = time.Now()
+	}
+
+	n.Paused = paused
+}
+
+// PlaybackTimeAt returns where playback is at the given time.
+func (n *NowPlayingMedia) PlaybackTimeAt(t time.Time) float64 {
 	if n.Paused {
 		return n.CurrentTime
 	}
 
-	return n.CurrentTime + float64(time.Since(n.lastChange).Seconds())
+	return n.CurrentTime + t.Sub(n.lastChange).Seconds()*n.PlaybackRate
+}
+
+func (n *NowPlayingMedia) CurrentPlaybackTime() float64 {
+	return n.PlaybackTimeAt(time.Now())
+}
+
+// State returns the current playback state, stamped with the server time it refers to.
+func (n *NowPlayingMedia) State() PlaybackState {
+	now := time.Now()
+
+	return PlaybackState{
+		Paused:       n.Paused,
+		CurrentTime:  n.PlaybackTimeAt(now),
+		PlaybackRate: n.PlaybackRate,
+		ServerTime:   now.UnixMilli(),
+	}
+}
+
+// Snapshot returns a copy of the media with the current time brought up to date.
+func (n *NowPlayingMedia) Snapshot() *NowPlayingMedia {
+	state := n.State()
+
+	return &NowPlayingMedia{
+		Media:        n.Media,
+		Paused:       state.Paused,
+		CurrentTime:  state.CurrentTime,
+		PlaybackRate: state.PlaybackRate,
+		ServerTime:   state.ServerTime,
+	}
+}
+
+type StartScheduled struct {
+	EntryID string `json:"entry_id"`
+	// The server time in unix milliseconds the media will start at.
+	StartAt int64     `json:"start_at"`
+	State   RoomState `json:"state"`
+}
+
+type Countdown struct {
+	EntryID string `json:"entry_id"`
+	StartAt int64  `json:"start_at"`
+	// The seconds left until the media starts.
+	Remaining float64 `json:"remaining"`
+}
+
+type MediaEnded struct {
+	EntryID string    `json:"entry_id"`
+	State   RoomState `json:"state"`
+}
+
+type HistoryEntry struct {
+	Media
+	PlayedAt int64 `json:"played_at"`
+}
+
+type HistoryUpdated struct {
+	History []HistoryEntry `json:"history"`
 }
 
 type PlaybackStateUpdated struct {
-	Paused      *bool    `json:"paused"`
-	CurrentTime *float64 `json:"current_time"`
+	Paused       *bool    `json:"paused"`
+	CurrentTime  *float64 `json:"current_time"`
+	PlaybackRate *float64 `json:"playback_rate"`
 }
 
 type MessageType int
@@ -87,6 +392,7 @@ const (
 	MessageTypeMediaChanged
 	MessageTypeMediaQueued
 	MessageTypeMediaRemoved
+	MessageTypeMediaMoved
 )
 
 type ChannelMessage struct {
@@ -102,6 +408,9 @@ const (
 	CommandTypeTakeRemote CommandType = iota
 	CommandTypePurgeMessages
 	CommandTypeSkip
+	CommandTypeShuffle
+	CommandTypePrevious
+	CommandTypeSkipSegment
 )
 
 type Command struct {
@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	// How close a seek has to be to the current time to be treated as not changing it, in seconds.
+	SeekTolerance = 0.25
+
+	ErrInvalidCurrentTime  = errors.New("the current time must be a finite number")
+	ErrInvalidPlaybackRate = errors.New("the playback rate must be a finite positive number")
+)
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// Checks a state update from the controller against the playing media, clamping it to what the media allows.
+// Fields that would not change anything are cleared, leaving an empty update when there is nothing to do.
+func validateState(playing *NowPlayingMedia, state PlaybackStateUpdated) (PlaybackStateUpdated, error) {
+	if state.PlaybackRate != nil {
+		if !finite(*state.PlaybackRate) || *state.PlaybackRate <= 0 {
+			return state, ErrInvalidPlaybackRate
+		}
+
+		rate := max(MinPlaybackRate, min(MaxPlaybackRate, *state.PlaybackRate))
+		state.PlaybackRate = &rate
+
+		if rate == playing.PlaybackRate {
+			state.PlaybackRate = nil
+		}
+	}
+
+	if state.CurrentTime != nil {
+		if !finite(*state.CurrentTime) {
+			return state, ErrInvalidCurrentTime
+		}
+
+		currentTime := max(0, min(playing.Duration, *state.CurrentTime))
+		state.CurrentTime = &currentTime
+
+		if math.Abs(currentTime-playing.CurrentPlaybackTime()) < SeekTolerance {
+			state.CurrentTime = nil
+		}
+	}
+
+	if state.Paused != nil && *state.Paused == playing.Paused {
+		state.Paused = nil
+	}
+
+	return state, nil
+}
+
+func (s PlaybackStateUpdated) empty() bool {
+	return s.Paused == nil && s.CurrentTime == nil && s.PlaybackRate == nil
+}